@@ -0,0 +1,211 @@
+// Package secp256k1 implements the secp256k1 elliptic curve and ECDSA signature verification as used in the Bitcoin protocol
+package secp256k1
+
+import (
+	"math/big"
+)
+
+// The curve is y² = x³ + B over the prime field P with base point G of order N
+var (
+	P  = fromHex("fffffffffffffffffffffffffffffffffffffffffffffffffffffffefffffc2f")
+	N  = fromHex("fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141")
+	B  = big.NewInt(7)
+	Gx = fromHex("79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798")
+	Gy = fromHex("483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b8")
+)
+
+const BitSize = 256
+
+func fromHex(s string) *big.Int {
+	n, ok := new(big.Int).SetString(s, 16)
+	if !ok {
+		panic("secp256k1: invalid constant " + s)
+	}
+	return n
+}
+
+// Points are held in Jacobian coordinates (X/Z², Y/Z³), Z == 0 is the point at infinity
+type jacobianPoint struct {
+	x, y, z *big.Int
+}
+
+func newJacobianPoint(x, y *big.Int) *jacobianPoint {
+	return &jacobianPoint{
+		x: new(big.Int).Set(x),
+		y: new(big.Int).Set(y),
+		z: big.NewInt(1),
+	}
+}
+
+func infinity() *jacobianPoint {
+	return &jacobianPoint{
+		x: new(big.Int),
+		y: new(big.Int),
+		z: new(big.Int),
+	}
+}
+
+func (this *jacobianPoint) isInfinity() bool {
+	return this.z.Sign() == 0
+}
+
+func (this *jacobianPoint) affine() (x, y *big.Int) {
+	if this.isInfinity() {
+		return new(big.Int), new(big.Int)
+	}
+
+	zInv := new(big.Int).ModInverse(this.z, P)
+	zInv2 := new(big.Int).Mul(zInv, zInv)
+	zInv2.Mod(zInv2, P)
+
+	x = new(big.Int).Mul(this.x, zInv2)
+	x.Mod(x, P)
+
+	zInv3 := zInv2.Mul(zInv2, zInv)
+	y = new(big.Int).Mul(this.y, zInv3)
+	y.Mod(y, P)
+
+	return x, y
+}
+
+func mulMod(a, b *big.Int) *big.Int {
+	r := new(big.Int).Mul(a, b)
+	return r.Mod(r, P)
+}
+
+func subMod(a, b *big.Int) *big.Int {
+	r := new(big.Int).Sub(a, b)
+	return r.Mod(r, P)
+}
+
+func (this *jacobianPoint) double() *jacobianPoint {
+	if this.isInfinity() || this.y.Sign() == 0 {
+		return infinity()
+	}
+
+	a := mulMod(this.x, this.x)
+	b := mulMod(this.y, this.y)
+	c := mulMod(b, b)
+
+	xb := new(big.Int).Add(this.x, b)
+	d := subMod(subMod(mulMod(xb, xb), a), c)
+	d = d.Lsh(d, 1).Mod(d, P)
+
+	e := new(big.Int).Mul(a, big.NewInt(3))
+	e.Mod(e, P)
+	f := mulMod(e, e)
+
+	x3 := subMod(f, new(big.Int).Lsh(d, 1))
+
+	c8 := new(big.Int).Lsh(c, 3)
+	y3 := subMod(mulMod(e, subMod(d, x3)), c8)
+
+	z3 := mulMod(this.y, this.z)
+	z3 = z3.Lsh(z3, 1).Mod(z3, P)
+
+	return &jacobianPoint{x: x3, y: y3, z: z3}
+}
+
+func (this *jacobianPoint) add(other *jacobianPoint) *jacobianPoint {
+	if this.isInfinity() {
+		return other
+	}
+	if other.isInfinity() {
+		return this
+	}
+
+	z1z1 := mulMod(this.z, this.z)
+	z2z2 := mulMod(other.z, other.z)
+
+	u1 := mulMod(this.x, z2z2)
+	u2 := mulMod(other.x, z1z1)
+
+	s1 := mulMod(mulMod(this.y, other.z), z2z2)
+	s2 := mulMod(mulMod(other.y, this.z), z1z1)
+
+	if u1.Cmp(u2) == 0 {
+		if s1.Cmp(s2) == 0 {
+			return this.double()
+		}
+		return infinity()
+	}
+
+	h := subMod(u2, u1)
+	h2 := new(big.Int).Lsh(h, 1)
+	i := mulMod(h2, h2)
+	j := mulMod(h, i)
+
+	r := subMod(s2, s1)
+	r = r.Lsh(r, 1).Mod(r, P)
+
+	v := mulMod(u1, i)
+
+	x3 := subMod(subMod(mulMod(r, r), j), new(big.Int).Lsh(v, 1))
+
+	s1j := mulMod(s1, j)
+	y3 := subMod(mulMod(r, subMod(v, x3)), s1j.Lsh(s1j, 1))
+
+	zsum := new(big.Int).Add(this.z, other.z)
+	z3 := mulMod(subMod(subMod(mulMod(zsum, zsum), z1z1), z2z2), h)
+
+	return &jacobianPoint{x: x3, y: y3, z: z3}
+}
+
+// IsOnCurve reports whether the affine point (x, y) lies on the curve
+func IsOnCurve(x, y *big.Int) bool {
+	if x.Sign() < 0 || x.Cmp(P) >= 0 || y.Sign() < 0 || y.Cmp(P) >= 0 {
+		return false
+	}
+
+	y2 := mulMod(y, y)
+
+	x3 := mulMod(mulMod(x, x), x)
+	x3.Add(x3, B)
+	x3.Mod(x3, P)
+
+	return y2.Cmp(x3) == 0
+}
+
+// ScalarBaseMult returns k*G
+func ScalarBaseMult(k []byte) (x, y *big.Int) {
+	return ScalarMult(Gx, Gy, k)
+}
+
+// ScalarMult returns k*(x, y)
+func ScalarMult(x, y *big.Int, k []byte) (rx, ry *big.Int) {
+	return doubleScalarMult(x, y, new(big.Int).SetBytes(k), nil, nil, new(big.Int)).affine()
+}
+
+// doubleScalarMult computes k1*(x1, y1) + k2*(x2, y2) with a single chain of doublings
+func doubleScalarMult(x1, y1, k1, x2, y2, k2 *big.Int) *jacobianPoint {
+	p1 := newJacobianPoint(x1, y1)
+
+	var p2, sum *jacobianPoint
+	if x2 != nil {
+		p2 = newJacobianPoint(x2, y2)
+		sum = p1.add(p2)
+	}
+
+	result := infinity()
+	bitLen := k1.BitLen()
+	if k2.BitLen() > bitLen {
+		bitLen = k2.BitLen()
+	}
+
+	for i := bitLen - 1; i >= 0; i-- {
+		result = result.double()
+
+		b1 := k1.Bit(i)
+		b2 := k2.Bit(i)
+		switch {
+		case b1 == 1 && b2 == 1:
+			result = result.add(sum)
+		case b1 == 1:
+			result = result.add(p1)
+		case b2 == 1:
+			result = result.add(p2)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,45 @@
+package secp256k1
+
+import (
+	"math/big"
+)
+
+// Verify reports whether sig is a valid signature of hash by the public key pk.
+func Verify(pk *PublicKey, hash []byte, sig *Signature) bool {
+	if sig.R.Sign() <= 0 || sig.R.Cmp(N) >= 0 {
+		return false
+	}
+	if sig.S.Sign() <= 0 || sig.S.Cmp(N) >= 0 {
+		return false
+	}
+
+	e := hashToInt(hash)
+
+	w := new(big.Int).ModInverse(sig.S, N)
+
+	u1 := e.Mul(e, w)
+	u1.Mod(u1, N)
+
+	u2 := w.Mul(sig.R, w)
+	u2.Mod(u2, N)
+
+	point := doubleScalarMult(Gx, Gy, u1, pk.X, pk.Y, u2)
+	if point.isInfinity() {
+		return false
+	}
+
+	x, _ := point.affine()
+	x.Mod(x, N)
+
+	return x.Cmp(sig.R) == 0
+}
+
+// hashToInt converts a hash to an integer using its leftmost BitSize bits
+func hashToInt(hash []byte) *big.Int {
+	orderBytes := BitSize / 8
+	if len(hash) > orderBytes {
+		hash = hash[:orderBytes]
+	}
+
+	return new(big.Int).SetBytes(hash)
+}
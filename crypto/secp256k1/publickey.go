@@ -0,0 +1,91 @@
+package secp256k1
+
+import (
+	"errors"
+	"math/big"
+)
+
+var ErrInvalidPublicKeyEncoding = errors.New("Invalid Public Key Encoding")
+var ErrPublicKeyNotOnCurve = errors.New("Public Key Not On Curve")
+
+type PublicKey struct {
+	X *big.Int
+	Y *big.Int
+}
+
+const (
+	pubKeyCompressedEven = 0x02
+	pubKeyCompressedOdd  = 0x03
+	pubKeyUncompressed   = 0x04
+	pubKeyHybridEven     = 0x06
+	pubKeyHybridOdd      = 0x07
+)
+
+// sqrtExp is (P+1)/4, P ≡ 3 mod 4 so a^sqrtExp is a square root of a when one exists
+var sqrtExp = new(big.Int).Rsh(new(big.Int).Add(P, big.NewInt(1)), 2)
+
+// ParsePublicKey decodes a compressed (33 byte), uncompressed (65 byte) or hybrid (65 byte) public key.
+func ParsePublicKey(b []byte) (*PublicKey, error) {
+	if len(b) == 0 {
+		return nil, ErrInvalidPublicKeyEncoding
+	}
+
+	pk := new(PublicKey)
+
+	switch b[0] {
+	case pubKeyCompressedEven, pubKeyCompressedOdd:
+		if len(b) != 33 {
+			return nil, ErrInvalidPublicKeyEncoding
+		}
+
+		pk.X = new(big.Int).SetBytes(b[1:33])
+		if pk.X.Cmp(P) >= 0 {
+			return nil, ErrPublicKeyNotOnCurve
+		}
+
+		y2 := mulMod(mulMod(pk.X, pk.X), pk.X)
+		y2.Add(y2, B)
+		y2.Mod(y2, P)
+
+		pk.Y = new(big.Int).Exp(y2, sqrtExp, P)
+		if pk.Y.Bit(0) != uint(b[0]&1) {
+			pk.Y.Sub(P, pk.Y)
+		}
+	case pubKeyUncompressed, pubKeyHybridEven, pubKeyHybridOdd:
+		if len(b) != 65 {
+			return nil, ErrInvalidPublicKeyEncoding
+		}
+
+		pk.X = new(big.Int).SetBytes(b[1:33])
+		pk.Y = new(big.Int).SetBytes(b[33:65])
+
+		if b[0] != pubKeyUncompressed && pk.Y.Bit(0) != uint(b[0]&1) {
+			return nil, ErrInvalidPublicKeyEncoding
+		}
+	default:
+		return nil, ErrInvalidPublicKeyEncoding
+	}
+
+	if !IsOnCurve(pk.X, pk.Y) {
+		return nil, ErrPublicKeyNotOnCurve
+	}
+
+	return pk, nil
+}
+
+// SerializeCompressed encodes the public key in the 33 byte compressed form.
+func (this *PublicKey) SerializeCompressed() []byte {
+	b := make([]byte, 33)
+	b[0] = pubKeyCompressedEven | byte(this.Y.Bit(0))
+	this.X.FillBytes(b[1:33])
+	return b
+}
+
+// SerializeUncompressed encodes the public key in the 65 byte uncompressed form.
+func (this *PublicKey) SerializeUncompressed() []byte {
+	b := make([]byte, 65)
+	b[0] = pubKeyUncompressed
+	this.X.FillBytes(b[1:33])
+	this.Y.FillBytes(b[33:65])
+	return b
+}
@@ -0,0 +1,331 @@
+package secp256k1
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"github.com/spearson78/guardian/transaction"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+var vectors = []struct {
+	privateKey   string
+	uncompressed string
+	compressed   string
+	hash         string
+	sig          string
+}{
+	{
+		privateKey:   "01",
+		uncompressed: "0479be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b8",
+		compressed:   "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798",
+		hash:         "996d4bcab946e4d190983707a31b8c960344607e775d8447ba92a2b603884039",
+		sig:          "3045022100c9105bd85b4bb12419d7fbb7918ea037e0afb4b9bebf6bab7981e984f9ed4964022014ec9a08469b244ce0cdf519e53b156e910998507e30f1a4f02837ed9c440be6",
+	},
+	{
+		privateKey:   "c0ffee",
+		uncompressed: "042a5bbcb0eede528e6abe5f2ec50ad7887eb5677af383a460b05ee23bf892dfe552c93747550eda8404c8b473786c00dfd8fd1ef4bc033f359ccf5b77bd656d21",
+		compressed:   "032a5bbcb0eede528e6abe5f2ec50ad7887eb5677af383a460b05ee23bf892dfe5",
+		hash:         "271dfc36976e39a9e49331a4df4af037c81624549c2e0e5fe2650f3fc0856a02",
+		sig:          "3045022100bc0184c680ab5663a922a16f3e2d255bf40afa049efe9f098ee5ee2ab318122c022057ddeec54059e4e0dca9bed825670fbada6a1cda0c69f05807c9345a01a846e5",
+	},
+	{
+		privateKey:   "e8f32e723decf4051aefac8e2c93c9c5b214313817cdb01a1494b917c8436b35",
+		uncompressed: "0439a36013301597daef41fbe593a02cc513d0b55527ec2df1050e2e8ff49c85c23cbe7ded0e7ce6a594896b8f62888fdbc5c8821305e2ea42bf01e37300116281",
+		compressed:   "0339a36013301597daef41fbe593a02cc513d0b55527ec2df1050e2e8ff49c85c2",
+		hash:         "9636d1c5c252ea3d697debf156f885ce54045f30359e2bfc64b8c7f142dd4e97",
+		sig:          "3045022100b6c7e147a7931a3b40ec3242b068271e4f47a3facf494cfa6bd984db8cae5b53022016c11e27882803a79f6da0d73676e2cc9eb8b9a10ad785080fb46fee10930f0b",
+	},
+}
+
+func decodeHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestScalarBaseMult(t *testing.T) {
+	for _, v := range vectors {
+		x, y := ScalarBaseMult(decodeHex(v.privateKey))
+
+		pk := &PublicKey{X: x, Y: y}
+		if !bytes.Equal(pk.SerializeUncompressed(), decodeHex(v.uncompressed)) {
+			t.Errorf("ScalarBaseMult %s incorrect %s", v.privateKey, hex.EncodeToString(pk.SerializeUncompressed()))
+		}
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	for _, v := range vectors {
+		uncompressed, err := ParsePublicKey(decodeHex(v.uncompressed))
+		if err != nil {
+			t.Errorf("ParsePublicKey uncompressed %s failed %v", v.privateKey, err)
+			continue
+		}
+
+		compressed, err := ParsePublicKey(decodeHex(v.compressed))
+		if err != nil {
+			t.Errorf("ParsePublicKey compressed %s failed %v", v.privateKey, err)
+			continue
+		}
+
+		if compressed.X.Cmp(uncompressed.X) != 0 || compressed.Y.Cmp(uncompressed.Y) != 0 {
+			t.Errorf("ParsePublicKey %s compressed and uncompressed differ", v.privateKey)
+		}
+
+		if !bytes.Equal(uncompressed.SerializeCompressed(), decodeHex(v.compressed)) {
+			t.Errorf("SerializeCompressed %s incorrect %s", v.privateKey, hex.EncodeToString(uncompressed.SerializeCompressed()))
+		}
+
+		hybrid := decodeHex(v.uncompressed)
+		hybrid[0] = 0x06 | byte(uncompressed.Y.Bit(0))
+		_, err = ParsePublicKey(hybrid)
+		if err != nil {
+			t.Errorf("ParsePublicKey hybrid %s failed %v", v.privateKey, err)
+		}
+
+		hybrid[0] ^= 0x01
+		_, err = ParsePublicKey(hybrid)
+		if err != ErrInvalidPublicKeyEncoding {
+			t.Errorf("ParsePublicKey hybrid %s wrong parity accepted %v", v.privateKey, err)
+		}
+	}
+
+	invalid := []struct {
+		pk  string
+		err error
+	}{
+		{"", ErrInvalidPublicKeyEncoding},
+		{"05", ErrInvalidPublicKeyEncoding},
+		{"0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f817", ErrInvalidPublicKeyEncoding},
+		{"0479be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b9", ErrPublicKeyNotOnCurve},
+		{"02fffffffffffffffffffffffffffffffffffffffffffffffffffffffefffffc2f", ErrPublicKeyNotOnCurve},
+	}
+
+	for _, test := range invalid {
+		_, err := ParsePublicKey(decodeHex(test.pk))
+		if err != test.err {
+			t.Errorf("ParsePublicKey %s expected %v got %v", test.pk, test.err, err)
+		}
+	}
+}
+
+func TestParseSignature(t *testing.T) {
+	for _, v := range vectors {
+		sig, err := ParseSignature(decodeHex(v.sig))
+		if err != nil {
+			t.Errorf("ParseSignature %s failed %v", v.sig, err)
+			continue
+		}
+
+		if !bytes.Equal(sig.Serialize(), decodeHex(v.sig)) {
+			t.Errorf("Serialize %s incorrect %s", v.sig, hex.EncodeToString(sig.Serialize()))
+		}
+	}
+
+	invalid := []string{
+		"",
+		"30",
+		"3006",
+		"31060201010201",
+		"3006020101030101",
+		"3006020201",
+		"3006020101020201",
+		"3085ffffffff",
+		//Zero length integers
+		"300502000201",
+		"30050201010200",
+		//Negative integers
+		"3006020180020101",
+		"3006020101020181",
+		//Integers wider than 256 bits
+		"3026022101" + strings.Repeat("00", 32) + "020101",
+		"3027022200" + "80" + strings.Repeat("00", 32) + "020101",
+	}
+
+	for _, test := range invalid {
+		_, err := ParseSignature(decodeHex(test))
+		if err != ErrInvalidSignatureEncoding {
+			t.Errorf("ParseSignature %s expected error got %v", test, err)
+		}
+	}
+
+	lax := []struct {
+		der string
+		r   int64
+		s   int64
+	}{
+		//Integers are unsigned and may be empty
+		{"3006020180020181", 0x80, 0x81},
+		{"30050201010200", 1, 0},
+		//Integers wider than 256 bits never verify
+		{"3026022101" + strings.Repeat("00", 32) + "020101", 0, 0},
+		//Sequence length is ignored
+		{"3050020101020102", 1, 2},
+		//Trailing garbage is ignored
+		{"3006020101020102ffff", 1, 2},
+		//Long form and padded lengths
+		{"3081080281010102820001020000", 1, 2},
+		//Excess leading zeros
+		{"3009020300000102020203", 1, 0x0203},
+	}
+
+	for _, test := range lax {
+		sig, err := ParseLaxSignature(decodeHex(test.der))
+		if err != nil {
+			t.Errorf("ParseLaxSignature %s failed %v", test.der, err)
+			continue
+		}
+
+		if sig.R.Int64() != test.r || sig.S.Int64() != test.s {
+			t.Errorf("ParseLaxSignature %s incorrect r %v s %v", test.der, sig.R, sig.S)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	for _, v := range vectors {
+		sig, _ := ParseSignature(decodeHex(v.sig))
+		hash := decodeHex(v.hash)
+
+		for _, encoded := range []string{v.uncompressed, v.compressed} {
+			pk, _ := ParsePublicKey(decodeHex(encoded))
+
+			if !Verify(pk, hash, sig) {
+				t.Errorf("Verify %s failed", v.privateKey)
+			}
+
+			highS := &Signature{R: sig.R, S: new(big.Int).Sub(N, sig.S)}
+			if !Verify(pk, hash, highS) {
+				t.Errorf("Verify %s high S failed", v.privateKey)
+			}
+
+			badHash := decodeHex(v.hash)
+			badHash[0] ^= 0x01
+			if Verify(pk, badHash, sig) {
+				t.Errorf("Verify %s accepted wrong hash", v.privateKey)
+			}
+
+			badSig := &Signature{R: sig.S, S: sig.R}
+			if Verify(pk, hash, badSig) {
+				t.Errorf("Verify %s accepted swapped signature", v.privateKey)
+			}
+
+			zero := &Signature{R: new(big.Int), S: sig.S}
+			if Verify(pk, hash, zero) {
+				t.Errorf("Verify %s accepted zero R", v.privateKey)
+			}
+		}
+	}
+}
+
+type fixedHash struct {
+	hash       []byte
+	inputIndex int
+	subScript  []byte
+	hashType   uint32
+}
+
+func (this *fixedHash) SignatureHash(inputIndex int, subScript []byte, hashType uint32) ([]byte, error) {
+	this.inputIndex = inputIndex
+	this.subScript = subScript
+	this.hashType = hashType
+
+	if this.hash == nil {
+		return nil, errors.New("No Hash")
+	}
+	return this.hash, nil
+}
+
+func TestSignatureCheck(t *testing.T) {
+	v := vectors[1]
+
+	hasher := &fixedHash{hash: decodeHex(v.hash)}
+	check := &SignatureCheck{
+		Tx:         hasher,
+		InputIndex: 3,
+	}
+
+	subScript := []byte{0xac}
+
+	err := check.CheckSig(decodeHex(v.compressed), 1, decodeHex(v.sig), subScript)
+	if err != nil {
+		t.Errorf("CheckSig failed %v", err)
+	}
+
+	if hasher.inputIndex != 3 || hasher.hashType != 1 || !bytes.Equal(hasher.subScript, subScript) {
+		t.Errorf("CheckSig hashed incorrect input %d hashType %d subScript %x", hasher.inputIndex, hasher.hashType, hasher.subScript)
+	}
+
+	err = check.CheckSig(decodeHex(vectors[0].compressed), 1, decodeHex(v.sig), subScript)
+	if err != ErrSignatureVerification {
+		t.Errorf("CheckSig wrong key expected ErrSignatureVerification got %v", err)
+	}
+
+	err = check.CheckSig(decodeHex(v.compressed)[1:], 1, decodeHex(v.sig), subScript)
+	if err != ErrInvalidPublicKeyEncoding {
+		t.Errorf("CheckSig bad key expected ErrInvalidPublicKeyEncoding got %v", err)
+	}
+
+	err = check.CheckSig(decodeHex(v.compressed), 1, decodeHex(v.sig)[1:], subScript)
+	if err != ErrInvalidSignatureEncoding {
+		t.Errorf("CheckSig bad signature expected ErrInvalidSignatureEncoding got %v", err)
+	}
+
+	hasher.hash = nil
+	err = check.CheckSig(decodeHex(v.compressed), 1, decodeHex(v.sig), subScript)
+	if err == nil {
+		t.Errorf("CheckSig expected hash error")
+	}
+}
+
+func TestSignatureCheckMainnet(t *testing.T) {
+
+	//Transaction afd9c17f8913577ec3509520bd6e5d63e9c0fd2a5f70c787993b097ba6ca9fae input 0
+	raw := decodeHex("010000000370ac0a1ae588aaf284c308d67ca92c69a39e2db81337e563bf40c59da0a5cf63000000006a4730440220360d20baff382059040ba9be98947fd678fb08aab2bb0c172efa996fd8ece9b702201b4fb0de67f015c90e7ac8a193aeab486a1f587e0f54d0fb9552ef7f5ce6caec032103579ca2e6d107522f012cd00b52b9a65fb46f0c57b9b8b6e377c48f526a44741affffffff7d815b6447e35fbea097e00e028fb7dfbad4f3f0987b4734676c84f3fcd0e804010000006b483045022100c714310be1e3a9ff1c5f7cacc65c2d8e781fc3a88ceb063c6153bf950650802102200b2d0979c76e12bb480da635f192cc8dc6f905380dd4ac1ff35a4f68f462fffd032103579ca2e6d107522f012cd00b52b9a65fb46f0c57b9b8b6e377c48f526a44741affffffff3f1f097333e4d46d51f5e77b53264db8f7f5d2e18217e1099957d0f5af7713ee010000006c493046022100b663499ef73273a3788dea342717c2640ac43c5a1cf862c9e09b206fcb3f6bb8022100b09972e75972d9148f2bdd462e5cb69b57c1214b88fc55ca638676c07cfc10d8032103579ca2e6d107522f012cd00b52b9a65fb46f0c57b9b8b6e377c48f526a44741affffffff0380841e00000000001976a914bfb282c70c4191f45b5a6665cad1682f2c9cfdfb88ac80841e00000000001976a9149857cc07bed33a5cf12b9c5e0500b675d500c81188ace0fd1c00000000001976a91443c52850606c872403c0601e69fa34b26f62db4a88ac00000000")
+	pk := decodeHex("03579ca2e6d107522f012cd00b52b9a65fb46f0c57b9b8b6e377c48f526a44741a")
+	sig := decodeHex("30440220360d20baff382059040ba9be98947fd678fb08aab2bb0c172efa996fd8ece9b702201b4fb0de67f015c90e7ac8a193aeab486a1f587e0f54d0fb9552ef7f5ce6caec")
+	subScript := decodeHex("76a914dcf72c4fd02f5a987cf9b02f2fabfcac3341a87d88ac")
+
+	var tx transaction.Tx
+	err := tx.Set(raw)
+	if err != nil {
+		t.Fatalf("Tx.Set failed %v", err)
+	}
+
+	check := &SignatureCheck{
+		Tx:         &tx,
+		InputIndex: 0,
+	}
+
+	err = check.CheckSig(pk, transaction.SigHashSingle, sig, subScript)
+	if err != nil {
+		t.Errorf("CheckSig mainnet failed %v", err)
+	}
+
+	err = check.CheckSig(pk, transaction.SigHashAll, sig, subScript)
+	if err != ErrSignatureVerification {
+		t.Errorf("CheckSig mainnet wrong hash type expected ErrSignatureVerification got %v", err)
+	}
+
+	check.InputIndex = 1
+	err = check.CheckSig(pk, transaction.SigHashSingle, sig, subScript)
+	if err != ErrSignatureVerification {
+		t.Errorf("CheckSig mainnet wrong input expected ErrSignatureVerification got %v", err)
+	}
+}
+
+func BenchmarkVerify(b *testing.B) {
+	v := vectors[2]
+	sig, _ := ParseSignature(decodeHex(v.sig))
+	pk, _ := ParsePublicKey(decodeHex(v.compressed))
+	hash := decodeHex(v.hash)
+
+	for i := 0; i < b.N; i++ {
+		Verify(pk, hash, sig)
+	}
+}
@@ -0,0 +1,150 @@
+package secp256k1
+
+import (
+	"errors"
+	"math/big"
+)
+
+var ErrInvalidSignatureEncoding = errors.New("Invalid Signature Encoding")
+
+type Signature struct {
+	R *big.Int
+	S *big.Int
+}
+
+// ParseSignature decodes a DER encoded signature.
+//
+// Length bytes may use the long form, the sequence length is not checked and trailing bytes are ignored.
+// R and S must be non empty, positive and fit into 256 bits.
+func ParseSignature(der []byte) (*Signature, error) {
+	return parseSignature(der, false)
+}
+
+// ParseLaxSignature decodes a signature as leniently as the reference client did before BIP66,
+// which is required to validate historic transactions.
+// R and S are read as unsigned so a set high bit is not negative, an empty integer is zero
+// and integers that do not fit into 256 bits yield a signature that never verifies.
+func ParseLaxSignature(der []byte) (*Signature, error) {
+	return parseSignature(der, true)
+}
+
+func parseSignature(der []byte, lax bool) (*Signature, error) {
+	pos := 0
+
+	if pos == len(der) || der[pos] != 0x30 {
+		return nil, ErrInvalidSignatureEncoding
+	}
+	pos++
+
+	if pos == len(der) {
+		return nil, ErrInvalidSignatureEncoding
+	}
+	lenByte := int(der[pos])
+	pos++
+	if lenByte&0x80 != 0 {
+		lenByte -= 0x80
+		if lenByte > len(der)-pos {
+			return nil, ErrInvalidSignatureEncoding
+		}
+		pos += lenByte
+	}
+
+	r, pos, err := parseInteger(der, pos, lax)
+	if err != nil {
+		return nil, err
+	}
+
+	s, _, err := parseInteger(der, pos, lax)
+	if err != nil {
+		return nil, err
+	}
+
+	sig := &Signature{
+		R: new(big.Int),
+		S: new(big.Int),
+	}
+
+	if len(r) <= 32 && len(s) <= 32 {
+		sig.R.SetBytes(r)
+		sig.S.SetBytes(s)
+	}
+
+	return sig, nil
+}
+
+func parseInteger(der []byte, pos int, lax bool) ([]byte, int, error) {
+
+	if pos == len(der) || der[pos] != 0x02 {
+		return nil, pos, ErrInvalidSignatureEncoding
+	}
+	pos++
+
+	if pos == len(der) {
+		return nil, pos, ErrInvalidSignatureEncoding
+	}
+	intLen := int(der[pos])
+	pos++
+	if intLen&0x80 != 0 {
+		lenBytes := intLen - 0x80
+		if lenBytes > len(der)-pos {
+			return nil, pos, ErrInvalidSignatureEncoding
+		}
+		for lenBytes > 0 && der[pos] == 0 {
+			pos++
+			lenBytes--
+		}
+		if lenBytes >= 4 {
+			return nil, pos, ErrInvalidSignatureEncoding
+		}
+		intLen = 0
+		for ; lenBytes > 0; lenBytes-- {
+			intLen = intLen<<8 | int(der[pos])
+			pos++
+		}
+	}
+
+	if intLen > len(der)-pos {
+		return nil, pos, ErrInvalidSignatureEncoding
+	}
+
+	value := der[pos : pos+intLen]
+	pos += intLen
+
+	//DER integers are signed so a set high bit is negative
+	if !lax && (len(value) == 0 || value[0]&0x80 != 0) {
+		return nil, pos, ErrInvalidSignatureEncoding
+	}
+
+	for len(value) > 0 && value[0] == 0 {
+		value = value[1:]
+	}
+
+	if !lax && len(value) > 32 {
+		return nil, pos, ErrInvalidSignatureEncoding
+	}
+
+	return value, pos, nil
+}
+
+// Serialize encodes the signature in strict DER form.
+func (this *Signature) Serialize() []byte {
+	r := derInteger(this.R)
+	s := derInteger(this.S)
+
+	der := make([]byte, 0, 6+len(r)+len(s))
+	der = append(der, 0x30, byte(4+len(r)+len(s)))
+	der = append(der, 0x02, byte(len(r)))
+	der = append(der, r...)
+	der = append(der, 0x02, byte(len(s)))
+	der = append(der, s...)
+
+	return der
+}
+
+func derInteger(n *big.Int) []byte {
+	b := n.Bytes()
+	if len(b) == 0 || b[0]&0x80 != 0 {
+		b = append([]byte{0x00}, b...)
+	}
+	return b
+}
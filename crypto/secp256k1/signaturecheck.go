@@ -0,0 +1,43 @@
+package secp256k1
+
+import (
+	"errors"
+)
+
+var ErrSignatureVerification = errors.New("Signature Verification Failed")
+
+// SignatureHasher produces the digest that is signed for an input of a transaction, *transaction.Tx implements it.
+type SignatureHasher interface {
+	SignatureHash(inputIndex int, subScript []byte, hashType uint32) ([]byte, error)
+}
+
+// SignatureCheck implements executor.SignatureCheck for one input of a transaction.
+type SignatureCheck struct {
+	Tx         SignatureHasher
+	InputIndex int
+}
+
+func (this *SignatureCheck) CheckSig(pk []byte, hashType uint32, sig []byte, subScript []byte) error {
+
+	publicKey, err := ParsePublicKey(pk)
+	if err != nil {
+		return err
+	}
+
+	//Consensus still accepts the signatures that were valid before BIP66
+	signature, err := ParseLaxSignature(sig)
+	if err != nil {
+		return err
+	}
+
+	hash, err := this.Tx.SignatureHash(this.InputIndex, subScript, hashType)
+	if err != nil {
+		return err
+	}
+
+	if !Verify(publicKey, hash, signature) {
+		return ErrSignatureVerification
+	}
+
+	return nil
+}
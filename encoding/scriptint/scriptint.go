@@ -1,9 +1,15 @@
 package scriptint
 
 import (
+	"errors"
 	"math/big"
 )
 
+var (
+	ErrNumberTooLong    = errors.New("Number Too Long")
+	ErrNonMinimalNumber = errors.New("Non Minimal Number Encoding")
+)
+
 func Encode(i *big.Int) []byte {
 
 	sign := i.Sign()
@@ -55,6 +61,23 @@ func Decode(b []byte) *big.Int {
 	return result
 }
 
+// DecodeMinimal is Decode for encodings of at most maxLen bytes without redundant leading zero bytes.
+// Only the most significant byte may hold the sign and it must be needed to do so, which also rules out negative zero.
+func DecodeMinimal(b []byte, maxLen int) (*big.Int, error) {
+	if len(b) > maxLen {
+		return nil, ErrNumberTooLong
+	}
+
+	if len(b) > 0 && b[len(b)-1]&0x7f == 0 {
+		//The last byte may only be a sign byte if the previous byte needs its high bit for the value
+		if len(b) == 1 || b[len(b)-2]&0x80 == 0 {
+			return nil, ErrNonMinimalNumber
+		}
+	}
+
+	return Decode(b), nil
+}
+
 func reverseInPlace(in []byte) {
 
 	l := len(in)
@@ -1,6 +1,7 @@
 package scriptint
 
 import (
+	"encoding/hex"
 	"math/big"
 	"testing"
 )
@@ -17,3 +18,44 @@ func Test(t *testing.T) {
 	}
 
 }
+
+func TestDecodeMinimal(t *testing.T) {
+	tests := []struct {
+		encoded string
+		maxLen  int
+		value   int64
+		err     error
+	}{
+		{"", 4, 0, nil},
+		{"01", 4, 1, nil},
+		{"81", 4, -1, nil},
+		{"7f", 4, 127, nil},
+		{"8000", 4, 128, nil},
+		{"8080", 4, -128, nil},
+		{"ff7f", 4, 32767, nil},
+		{"ffffffff", 4, -2147483647, nil},
+		{"ffffffff7f", 5, 549755813887, nil},
+		{"00", 4, 0, ErrNonMinimalNumber},
+		{"80", 4, 0, ErrNonMinimalNumber},
+		{"0000", 4, 0, ErrNonMinimalNumber},
+		{"0100", 4, 0, ErrNonMinimalNumber},
+		{"0180", 4, 0, ErrNonMinimalNumber},
+		{"7f00", 4, 0, ErrNonMinimalNumber},
+		{"ffffffff7f", 4, 0, ErrNumberTooLong},
+		{"0000000000", 4, 0, ErrNumberTooLong},
+	}
+
+	for _, test := range tests {
+		encoded, _ := hex.DecodeString(test.encoded)
+
+		n, err := DecodeMinimal(encoded, test.maxLen)
+		if err != test.err {
+			t.Errorf("%s expected error %v got %v", test.encoded, test.err, err)
+			continue
+		}
+
+		if err == nil && n.Cmp(big.NewInt(test.value)) != 0 {
+			t.Errorf("%s expected %d got %v", test.encoded, test.value, n)
+		}
+	}
+}
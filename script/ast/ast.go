@@ -55,14 +55,17 @@ type Number struct {
 	Value       *big.Int
 }
 
+// IfStmt holds the Body following IF or NOTIF and one Else block per ELSE.
+// Each ELSE toggles execution so Body and the odd numbered Else blocks run when the condition holds
+// and the even numbered Else blocks, starting with Else[0], run when it does not.
 type IfStmt struct {
 	ParentBlock Block
 	Not         bool
 	IfPos       int
-	ElsePos     int
+	ElsePos     []int
 	EndIfPos    int
 	Body        Block
-	Else        Block
+	Else        []Block
 }
 
 func (this *SimpleBlock) Parent() Block {
@@ -273,16 +276,12 @@ func (this *IfStmt) Equals(node Node) bool {
 		return false
 	}
 
-	if this.Else != nil {
-		if other.Else != nil {
-			if !this.Else.Equals(other.Else) {
-				return false
-			}
-		} else {
-			return false
-		}
-	} else {
-		if other.Else != nil {
+	if len(this.Else) != len(other.Else) {
+		return false
+	}
+
+	for i, thisElse := range this.Else {
+		if !thisElse.Equals(other.Else[i]) {
 			return false
 		}
 	}
@@ -290,6 +289,22 @@ func (this *IfStmt) Equals(node Node) bool {
 	return true
 }
 
+// Branches returns the blocks executed, in script order, when the condition has the given value.
+func (this *IfStmt) Branches(condition bool) []Block {
+	var branches []Block
+	if condition {
+		branches = append(branches, this.Body)
+	}
+
+	for i, elseBlock := range this.Else {
+		if condition == (i%2 == 1) {
+			branches = append(branches, elseBlock)
+		}
+	}
+
+	return branches
+}
+
 func (this *IfStmt) String() string {
 	var buffer bytes.Buffer
 	if this.Not {
@@ -298,9 +313,9 @@ func (this *IfStmt) String() string {
 		buffer.WriteString("IF\n")
 	}
 	buffer.WriteString(this.Body.String())
-	if this.Else != nil {
+	for _, elseBlock := range this.Else {
 		buffer.WriteString("ELSE\n")
-		buffer.WriteString(this.Else.String())
+		buffer.WriteString(elseBlock.String())
 	}
 	buffer.WriteString("ENDIF\n")
 
@@ -77,6 +77,10 @@ func TestStandardTransactionToBitcoinAddressScanner(t *testing.T) {
 		t.Errorf("Failed %v", err)
 	}
 
+	if errorReported {
+		t.Errorf("Scanner reported an error")
+	}
+
 	root := new(SimpleBlock)
 	root.NodeList = []Node{
 		&Operation{
@@ -135,10 +139,12 @@ ENDIF
 					},
 				},
 			},
-			Else: &SimpleBlock{
-				NodeList: []Node{
-					&Number{
-						Value: big.NewInt(2),
+			Else: []Block{
+				&SimpleBlock{
+					NodeList: []Node{
+						&Number{
+							Value: big.NewInt(2),
+						},
 					},
 				},
 			},
@@ -151,6 +157,106 @@ ENDIF
 
 }
 
+func TestMultipleElse(t *testing.T) {
+
+	script := `
+IF
+1
+ELSE
+2
+ELSE
+3
+ENDIF
+`
+
+	l := new(lexer.Lexer)
+	l.Init(strings.NewReader(script), nil)
+
+	tree, err := Parse(l)
+	if err != nil {
+		t.Fatalf("Failed %v", err)
+	}
+
+	number := func(n int64) Block {
+		return &SimpleBlock{
+			NodeList: []Node{
+				&Number{
+					Value: big.NewInt(n),
+				},
+			},
+		}
+	}
+
+	root := new(SimpleBlock)
+	root.NodeList = []Node{
+		&IfStmt{
+			Body: number(1),
+			Else: []Block{number(2), number(3)},
+		},
+	}
+
+	if !root.Equals(tree) {
+		t.Fatalf("AST Mismatch")
+	}
+
+	ifStmt := tree.List()[0].(*IfStmt)
+	if len(ifStmt.ElsePos) != 2 {
+		t.Errorf("ElsePos Mismatch %v", ifStmt.ElsePos)
+	}
+
+	testCases := []struct {
+		condition bool
+		branches  []Block
+	}{
+		{true, []Block{number(1), number(3)}},
+		{false, []Block{number(2)}},
+	}
+
+	for _, testCase := range testCases {
+		branches := ifStmt.Branches(testCase.condition)
+		if len(branches) != len(testCase.branches) {
+			t.Errorf("Branches %v Mismatch %v", testCase.condition, branches)
+			continue
+		}
+
+		for i, branch := range branches {
+			if !branch.Equals(testCase.branches[i]) {
+				t.Errorf("Branches %v Mismatch at %v", testCase.condition, i)
+			}
+		}
+	}
+
+}
+
+func TestUnbalancedIf(t *testing.T) {
+
+	testCases := []struct {
+		script string
+		err    error
+	}{
+		{"ELSE", ErrUnexpectedElse},
+		{"ENDIF", ErrUnexpectedEndIf},
+		{"1 IF ENDIF ELSE", ErrUnexpectedElse},
+		{"1 IF ENDIF ENDIF", ErrUnexpectedEndIf},
+		{"IF", ErrUnclosedIf},
+		{"IF ELSE ELSE", ErrUnclosedIf},
+		{"IF NOTIF ENDIF", ErrUnclosedIf},
+		{"IF ELSE ELSE ENDIF", nil},
+		{"NOTIF ELSE ELSE ELSE ENDIF", nil},
+	}
+
+	for _, testCase := range testCases {
+		l := new(lexer.Lexer)
+		l.Init(strings.NewReader(testCase.script), nil)
+
+		_, err := Parse(l)
+		if err != testCase.err {
+			t.Errorf("%v expected %v got %v", testCase.script, testCase.err, err)
+		}
+	}
+
+}
+
 func BenchmarkStandardTransactionToBitcoinAddress(b *testing.B) {
 	script, _ := hex.DecodeString("76A91489ABCDEFABBAABBAABBAABBAABBAABBAABBAABBA88AC")
 
@@ -7,6 +7,14 @@ import (
 	"math/big"
 )
 
+var (
+	ErrUnclosedIf       = errors.New("Unclosed IF Statements")
+	ErrUnexpectedElse   = errors.New("Unexpected Else")
+	ErrUnexpectedEndIf  = errors.New("Unexpected EndIf")
+	ErrInvalidToken     = errors.New("Invalid Token")
+	ErrTokenSourceError = errors.New("TokenSource reported errors")
+)
+
 type TokenSource interface {
 	Scan() (tok token.Token)
 
@@ -52,14 +60,20 @@ func Parse(s TokenSource) (Block, error) {
 		tok := s.Scan()
 		if tok == token.ENDOFSCRIPT {
 			if ifStack.Len() != 0 {
-				return nil, errors.New("Unclosed IF Statements")
+				return nil, ErrUnclosedIf
 			}
 			break
 		}
 
 		switch tok {
 		case token.INVALID:
-			return nil, errors.New("Invalid Token")
+			return nil, ErrInvalidToken
+		case token.BYTECODE:
+			//Raw bytecode has to be compiled and scanned before it can be parsed
+			return nil, errors.New("Unexpected ByteCode")
+		case token.PLACEHOLDER:
+			//Placeholders are only bound by the compiler
+			return nil, errors.New("Unbound Placeholder " + string(s.Data()))
 		case token.DATA:
 			currentBlock.Append(&Data{
 				ParentBlock: currentBlock,
@@ -106,11 +120,19 @@ func Parse(s TokenSource) (Block, error) {
 			currentBlock = ifStmt.Body
 			ifStack.Push(ifStmt)
 		case token.ELSE:
+			if ifStack.Len() == 0 {
+				return nil, ErrUnexpectedElse
+			}
 			currentIf := ifStack.Peek()
-			currentIf.ElsePos = s.Pos()
-			currentIf.Else = new(SimpleBlock)
-			currentBlock = currentIf.Else
+			//Every ELSE starts a new branch, execution toggles between them
+			elseBlock := new(SimpleBlock)
+			currentIf.ElsePos = append(currentIf.ElsePos, s.Pos())
+			currentIf.Else = append(currentIf.Else, elseBlock)
+			currentBlock = elseBlock
 		case token.ENDIF:
+			if ifStack.Len() == 0 {
+				return nil, ErrUnexpectedEndIf
+			}
 			currentIf := ifStack.Pop()
 			currentIf.EndIfPos = s.Pos()
 			currentBlock = currentIf.ParentBlock
@@ -118,7 +140,7 @@ func Parse(s TokenSource) (Block, error) {
 	}
 
 	if s.ErrorCount() != 0 {
-		return nil, errors.New("TokenSource reported errors")
+		return nil, ErrTokenSourceError
 	}
 
 	return root, nil
@@ -0,0 +1,138 @@
+// Package script builds scripts programmatically, the subpackages scan, parse, compile and execute them.
+package script
+
+import (
+	"errors"
+	"github.com/spearson78/guardian/script/compiler"
+	"github.com/spearson78/guardian/script/limits"
+	"github.com/spearson78/guardian/script/opcode"
+	"github.com/spearson78/guardian/script/template"
+	"math/big"
+)
+
+var (
+	ErrPushSize      = errors.New("Push Size Limit Exceeded")
+	ErrScriptSize    = errors.New("Script Size Limit Exceeded")
+	ErrHashLength    = errors.New("Hash Must Be 20 Bytes")
+	ErrMultiSigCount = errors.New("Invalid MultiSig Key Count")
+	ErrNullDataSize  = errors.New("NullData Script Exceeds Standard Size")
+)
+
+// Builder appends operations and minimal pushes to a script.
+// The first error is kept and returned by Script, later calls have no effect.
+type Builder struct {
+	script []byte
+	err    error
+}
+
+func (this *Builder) Init() {
+	this.script = nil
+	this.err = nil
+}
+
+// AddOp appends a single operation.
+func (this *Builder) AddOp(op opcode.OpCode) *Builder {
+	if this.err != nil {
+		return this
+	}
+
+	this.script = append(this.script, byte(op))
+	return this.checkScriptSize()
+}
+
+// AddData appends the minimal push of data.
+// Empty data and single bytes representing -1 to 16 use the small number operations.
+func (this *Builder) AddData(data []byte) *Builder {
+	if this.err != nil {
+		return this
+	}
+
+	if len(data) > limits.MaxPushSize {
+		this.err = ErrPushSize
+		return this
+	}
+
+	this.script = compiler.AppendMinimalPushData(this.script, data)
+	return this.checkScriptSize()
+}
+
+// AddInt64 appends the minimal push of n as a script number.
+func (this *Builder) AddInt64(n int64) *Builder {
+	if this.err != nil {
+		return this
+	}
+
+	this.script = compiler.AppendNumber(this.script, big.NewInt(n))
+	return this.checkScriptSize()
+}
+
+func (this *Builder) checkScriptSize() *Builder {
+	if len(this.script) > limits.MaxScriptSize {
+		this.err = ErrScriptSize
+	}
+
+	return this
+}
+
+// Script returns the script built so far or the first error encountered.
+func (this *Builder) Script() ([]byte, error) {
+	if this.err != nil {
+		return nil, this.err
+	}
+
+	return this.script, nil
+}
+
+// PayToPubKeyHash returns DUP HASH160 <pubKeyHash> EQUALVERIFY CHECKSIG.
+func PayToPubKeyHash(pubKeyHash []byte) ([]byte, error) {
+	if len(pubKeyHash) != 20 {
+		return nil, ErrHashLength
+	}
+
+	var b Builder
+	b.Init()
+	return b.AddOp(opcode.DUP).AddOp(opcode.HASH160).AddData(pubKeyHash).AddOp(opcode.EQUALVERIFY).AddOp(opcode.CHECKSIG).Script()
+}
+
+// PayToScriptHash returns HASH160 <scriptHash> EQUAL.
+func PayToScriptHash(scriptHash []byte) ([]byte, error) {
+	if len(scriptHash) != 20 {
+		return nil, ErrHashLength
+	}
+
+	var b Builder
+	b.Init()
+	return b.AddOp(opcode.HASH160).AddData(scriptHash).AddOp(opcode.EQUAL).Script()
+}
+
+// MultiSig returns m <keys...> n CHECKMULTISIG, requiring 1 <= m <= n <= 16.
+func MultiSig(m int, keys [][]byte) ([]byte, error) {
+	if m < 1 || m > len(keys) || len(keys) > 16 {
+		return nil, ErrMultiSigCount
+	}
+
+	var b Builder
+	b.Init()
+	b.AddInt64(int64(m))
+	for _, key := range keys {
+		b.AddData(key)
+	}
+	return b.AddInt64(int64(len(keys))).AddOp(opcode.CHECKMULTISIG).Script()
+}
+
+// NullData returns RETURN <data>, an unspendable output carrying data.
+// Like the reference client data is always pushed as bytes, never as a small number operation,
+// so template.Match returns it unchanged. Scripts larger than template.MaxNullDataSize are rejected
+// as they would not be standard.
+func NullData(data []byte) ([]byte, error) {
+	if len(data) > limits.MaxPushSize {
+		return nil, ErrPushSize
+	}
+
+	script := compiler.AppendPushData([]byte{byte(opcode.RETURN)}, data)
+	if len(script) > template.MaxNullDataSize {
+		return nil, ErrNullDataSize
+	}
+
+	return script, nil
+}
@@ -0,0 +1,233 @@
+package script
+
+import (
+	"bytes"
+	"encoding/hex"
+	"github.com/spearson78/guardian/script/opcode"
+	"github.com/spearson78/guardian/script/template"
+	"strings"
+	"testing"
+)
+
+func TestAddData(t *testing.T) {
+	tests := []struct {
+		data   string
+		script string
+	}{
+		{"", "00"},
+		{"00", "0100"},
+		{"01", "51"},
+		{"10", "60"},
+		{"11", "0111"},
+		{"81", "4f"},
+		{"80", "0180"},
+		{"0102", "020102"},
+		{strings.Repeat("aa", 75), "4b" + strings.Repeat("aa", 75)},
+		{strings.Repeat("aa", 76), "4c4c" + strings.Repeat("aa", 76)},
+		{strings.Repeat("aa", 255), "4cff" + strings.Repeat("aa", 255)},
+		{strings.Repeat("aa", 256), "4d0001" + strings.Repeat("aa", 256)},
+		{strings.Repeat("aa", 520), "4d0802" + strings.Repeat("aa", 520)},
+	}
+
+	for _, test := range tests {
+		data, _ := hex.DecodeString(test.data)
+
+		var b Builder
+		b.Init()
+		script, err := b.AddData(data).Script()
+		if err != nil {
+			t.Errorf("%s Failed %v", test.data, err)
+			continue
+		}
+
+		if hex.EncodeToString(script) != test.script {
+			t.Errorf("%s expected %s got %x", test.data, test.script, script)
+		}
+	}
+}
+
+func TestAddInt64(t *testing.T) {
+	tests := []struct {
+		n      int64
+		script string
+	}{
+		{0, "00"},
+		{-1, "4f"},
+		{1, "51"},
+		{16, "60"},
+		{17, "0111"},
+		{-2, "0182"},
+		{127, "017f"},
+		{128, "028000"},
+		{-128, "028080"},
+		{1000, "02e803"},
+	}
+
+	for _, test := range tests {
+		var b Builder
+		b.Init()
+		script, err := b.AddInt64(test.n).Script()
+		if err != nil {
+			t.Errorf("%d Failed %v", test.n, err)
+			continue
+		}
+
+		if hex.EncodeToString(script) != test.script {
+			t.Errorf("%d expected %s got %x", test.n, test.script, script)
+		}
+	}
+}
+
+func TestBuilderErrors(t *testing.T) {
+	var b Builder
+	b.Init()
+	_, err := b.AddOp(opcode.DUP).AddData(make([]byte, 521)).AddOp(opcode.DROP).Script()
+	if err != ErrPushSize {
+		t.Errorf("Expected ErrPushSize got %v", err)
+	}
+
+	b.Init()
+	for i := 0; i < 10001; i++ {
+		b.AddOp(opcode.NOP)
+	}
+	_, err = b.Script()
+	if err != ErrScriptSize {
+		t.Errorf("Expected ErrScriptSize got %v", err)
+	}
+
+	b.Init()
+	script, err := b.AddOp(opcode.NOP).Script()
+	if err != nil || !bytes.Equal(script, []byte{0x61}) {
+		t.Errorf("Init did not reset the Builder %x %v", script, err)
+	}
+}
+
+func TestStandardScripts(t *testing.T) {
+	hash, _ := hex.DecodeString("010966776006953d5567439e5e39f86a0d273bee")
+	key, _ := hex.DecodeString("0250863ad64a87ae8a2fe83c1af1a8403cb53f53e486d8511dad8a04887e5b2352")
+
+	p2pkh, err := PayToPubKeyHash(hash)
+	if err != nil || hex.EncodeToString(p2pkh) != "76a914010966776006953d5567439e5e39f86a0d273bee88ac" {
+		t.Errorf("PayToPubKeyHash %x %v", p2pkh, err)
+	}
+
+	p2sh, err := PayToScriptHash(hash)
+	if err != nil || hex.EncodeToString(p2sh) != "a914010966776006953d5567439e5e39f86a0d273bee87" {
+		t.Errorf("PayToScriptHash %x %v", p2sh, err)
+	}
+
+	multiSig, err := MultiSig(1, [][]byte{key, key})
+	if err != nil || hex.EncodeToString(multiSig) != "5121"+hex.EncodeToString(key)+"21"+hex.EncodeToString(key)+"52ae" {
+		t.Errorf("MultiSig %x %v", multiSig, err)
+	}
+
+	nullData, err := NullData([]byte("hello"))
+	if err != nil || hex.EncodeToString(nullData) != "6a0568656c6c6f" {
+		t.Errorf("NullData %x %v", nullData, err)
+	}
+
+	tests := []struct {
+		script []byte
+		class  template.Class
+	}{
+		{p2pkh, template.PubKeyHash},
+		{p2sh, template.ScriptHash},
+		{multiSig, template.MultiSig},
+		{nullData, template.NullData},
+	}
+
+	for _, test := range tests {
+		if class := template.Match(test.script).Class; class != test.class {
+			t.Errorf("%x expected %v got %v", test.script, test.class, class)
+		}
+	}
+
+	if _, err := PayToPubKeyHash(hash[1:]); err != ErrHashLength {
+		t.Errorf("PayToPubKeyHash expected ErrHashLength got %v", err)
+	}
+
+	if _, err := PayToScriptHash(append(hash, 0)); err != ErrHashLength {
+		t.Errorf("PayToScriptHash expected ErrHashLength got %v", err)
+	}
+
+	keys17 := make([][]byte, 17)
+	for i := range keys17 {
+		keys17[i] = key
+	}
+
+	multiSigErrors := []struct {
+		m    int
+		keys [][]byte
+	}{
+		{0, [][]byte{key}},
+		{2, [][]byte{key}},
+		{1, nil},
+		{1, keys17},
+	}
+
+	for _, test := range multiSigErrors {
+		if _, err := MultiSig(test.m, test.keys); err != ErrMultiSigCount {
+			t.Errorf("MultiSig %d of %d expected ErrMultiSigCount got %v", test.m, len(test.keys), err)
+		}
+	}
+}
+
+func TestNullDataSize(t *testing.T) {
+	tests := []struct {
+		size int
+		err  error
+	}{
+		{0, nil},
+		{75, nil},
+		{80, nil},
+		{81, ErrNullDataSize},
+		{520, ErrNullDataSize},
+		{521, ErrPushSize},
+	}
+
+	for _, test := range tests {
+		script, err := NullData(bytes.Repeat([]byte{0xaa}, test.size))
+		if err != test.err {
+			t.Errorf("NullData %d bytes expected %v got %v", test.size, test.err, err)
+			continue
+		}
+
+		if err != nil {
+			continue
+		}
+
+		if len(script) > template.MaxNullDataSize {
+			t.Errorf("NullData %d bytes produced %d byte script", test.size, len(script))
+		}
+
+		if class := template.Match(script).Class; class != template.NullData {
+			t.Errorf("NullData %d bytes expected %v got %v", test.size, template.NullData, class)
+		}
+	}
+
+	//The first oversized script is the one template.Match rejects
+	var b Builder
+	b.Init()
+	oversized, _ := b.AddOp(opcode.RETURN).AddData(bytes.Repeat([]byte{0xaa}, 81)).Script()
+	if class := template.Match(oversized).Class; class != template.NonStandard {
+		t.Errorf("81 byte NullData expected %v got %v", template.NonStandard, class)
+	}
+}
+
+func TestNullDataRoundTrip(t *testing.T) {
+	tests := []string{"", "00", "01", "05", "10", "11", "81", "68656c6c6f", strings.Repeat("aa", 80)}
+
+	for _, test := range tests {
+		data, _ := hex.DecodeString(test)
+		script, err := NullData(data)
+		if err != nil {
+			t.Errorf("NullData %s Failed %v", test, err)
+			continue
+		}
+
+		match := template.Match(script)
+		if match.Class != template.NullData || len(match.Data) != 1 || !bytes.Equal(match.Data[0], data) {
+			t.Errorf("NullData %s script %x matched %v %x", test, script, match.Class, match.Data)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package compiler
+
+import (
+	"errors"
+	"fmt"
+	"github.com/spearson78/guardian/script/ast"
+)
+
+// CompileBlock emits the bytecode of a parsed or rewritten script.
+// Data and numbers use the same encodings as Compile so source compiled either way is identical.
+func CompileBlock(block ast.Block) ([]byte, error) {
+	return appendBlock(make([]byte, 0, 128), block)
+}
+
+func appendBlock(compiled []byte, block ast.Block) ([]byte, error) {
+	var err error
+
+	for _, node := range block.List() {
+		switch n := node.(type) {
+		case *ast.Data:
+			compiled = AppendPushData(compiled, n.Value)
+		case *ast.Number:
+			compiled = AppendNumber(compiled, n.Value)
+		case *ast.Operation:
+			compiled = append(compiled, byte(n.OpCode))
+		case *ast.CodeSeparator:
+			compiled = append(compiled, 0xab)
+			compiled, err = appendBlock(compiled, n)
+		case *ast.IfStmt:
+			if n.Not {
+				compiled = append(compiled, 0x64)
+			} else {
+				compiled = append(compiled, 0x63)
+			}
+
+			compiled, err = appendBlock(compiled, n.Body)
+			for i := 0; err == nil && i < len(n.Else); i++ {
+				compiled = append(compiled, 0x67)
+				compiled, err = appendBlock(compiled, n.Else[i])
+			}
+
+			compiled = append(compiled, 0x68)
+		case ast.Block:
+			compiled, err = appendBlock(compiled, n)
+		default:
+			return nil, errors.New(fmt.Sprintf("Unknown Node %T", node))
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return compiled, nil
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/spearson78/guardian/script/token"
 	"math"
 	"math/big"
+	"strings"
 )
 
 type TokenSource interface {
@@ -22,7 +23,8 @@ type TokenSource interface {
 var c16 = big.NewInt(16)
 var cMinus1 = big.NewInt(-1)
 
-func appendPushData(dst []byte, data []byte) []byte {
+// AppendPushData appends the push of data using the narrowest push operation for its length.
+func AppendPushData(dst []byte, data []byte) []byte {
 	l := len(data)
 	if l <= 75 {
 		dst = append(dst, byte(l))
@@ -38,29 +40,53 @@ func appendPushData(dst []byte, data []byte) []byte {
 	return dst
 }
 
-func Compile(s TokenSource) ([]byte, error) {
+// AppendMinimalPushData appends the shortest push of data,
+// using the small number operations for empty data and single bytes representing -1 to 16.
+func AppendMinimalPushData(dst []byte, data []byte) []byte {
+	switch {
+	case len(data) == 0:
+		return append(dst, 0x00)
+	case len(data) == 1 && data[0] >= 1 && data[0] <= 16:
+		return append(dst, 0x50+data[0])
+	case len(data) == 1 && data[0] == 0x81:
+		return append(dst, 0x4f)
+	}
+
+	return AppendPushData(dst, data)
+}
+
+// AppendNumber appends the push of n preferring the small number operations.
+func AppendNumber(dst []byte, n *big.Int) []byte {
+	switch {
+	case n.Sign() == 0:
+		return append(dst, 0x00)
+	case n.Cmp(cMinus1) == 0:
+		return append(dst, 0x4f)
+	case n.Sign() > 0 && n.Cmp(c16) <= 0:
+		return append(dst, byte(80+n.Int64()))
+	}
+
+	return AppendPushData(dst, scriptint.Encode(n))
+}
+
+// Bindings holds the data pushed in place of each PLACEHOLDER by name.
+type Bindings map[string][]byte
+
+// Compile compiles the tokens read from s, PLACEHOLDER tokens are replaced by the minimal push of their binding.
+func Compile(s TokenSource, bindings Bindings) ([]byte, error) {
 
 	compiled := make([]byte, 0, 128)
+	invalid := false
+	var unbound []string
 
 	tok := s.Scan()
 	for tok != token.ENDOFSCRIPT {
 
 		switch tok {
 		case token.DATA:
-			compiled = appendPushData(compiled, s.Data())
+			compiled = AppendPushData(compiled, s.Data())
 		case token.NUMBER:
-			n := s.Number()
-			switch {
-			case n.Sign() == 0:
-				compiled = append(compiled, 0x00)
-			case n.Cmp(cMinus1) == 0:
-				compiled = append(compiled, 0x4f)
-			case n.Sign() > 0 && n.Cmp(c16) <= 0:
-				compiled = append(compiled, byte(80+n.Int64()))
-			default:
-				data := scriptint.Encode(n)
-				compiled = appendPushData(compiled, data)
-			}
+			compiled = AppendNumber(compiled, s.Number())
 		case token.OPERATION:
 			compiled = append(compiled, byte(s.Op()))
 		case token.CODESEPARATOR:
@@ -73,8 +99,18 @@ func Compile(s TokenSource) ([]byte, error) {
 			compiled = append(compiled, 0x67)
 		case token.ENDIF:
 			compiled = append(compiled, 0x68)
+		case token.BYTECODE:
+			compiled = append(compiled, s.Data()...)
+		case token.PLACEHOLDER:
+			data, ok := bindings[string(s.Data())]
+			if !ok {
+				unbound = append(unbound, string(s.Data()))
+			}
+			//Bound values such as a lock time or key count must satisfy VerifyMinimalData
+			compiled = AppendMinimalPushData(compiled, data)
 		case token.INVALID:
-			return nil, errors.New("Invalid Token")
+			//Keep reading so the TokenSource can report every error
+			invalid = true
 		default:
 			return nil, errors.New("Unknown Token" + tok.String())
 		}
@@ -86,6 +122,14 @@ func Compile(s TokenSource) ([]byte, error) {
 		return nil, errors.New("TokenSource reported errors")
 	}
 
+	if invalid {
+		return nil, errors.New("Invalid Token")
+	}
+
+	if len(unbound) != 0 {
+		return nil, errors.New("Unbound Placeholders " + strings.Join(unbound, " "))
+	}
+
 	return compiled, nil
 
 }
@@ -3,6 +3,7 @@ package compiler
 import (
 	"bytes"
 	"encoding/hex"
+	"github.com/spearson78/guardian/script/ast"
 	"github.com/spearson78/guardian/script/lexer"
 	"github.com/spearson78/guardian/script/scanner"
 	"strings"
@@ -22,7 +23,7 @@ CHECKSIG
 	l := new(lexer.Lexer)
 	l.Init(strings.NewReader(script), nil)
 
-	compiled, _ := Compile(l)
+	compiled, _ := Compile(l, nil)
 
 	if !bytes.Equal(compiled, result) {
 		t.Errorf("Failed")
@@ -36,7 +37,7 @@ func TestStandardTransactionToBitcoinAddressFromScanner(t *testing.T) {
 	s := new(scanner.Scanner)
 	s.Init(script, nil)
 
-	compiled, _ := Compile(s)
+	compiled, _ := Compile(s, nil)
 
 	if !bytes.Equal(compiled, script) {
 		t.Errorf("Failed")
@@ -74,7 +75,7 @@ func TestNumbers(t *testing.T) {
 	l := new(lexer.Lexer)
 	l.Init(strings.NewReader(script), nil)
 
-	compiled, err := Compile(l)
+	compiled, err := Compile(l, nil)
 	if err != nil {
 		t.Errorf("Failed %v", err)
 	}
@@ -84,3 +85,153 @@ func TestNumbers(t *testing.T) {
 	}
 
 }
+
+func TestReferenceByteCode(t *testing.T) {
+	script := `0x4c 0x03 0x222222 'Az' 1ADD 1000`
+	result, _ := hex.DecodeString("4c0322222202417a8b02e803")
+
+	l := new(lexer.ReferenceLexer)
+	l.Init(strings.NewReader(script), nil)
+
+	compiled, err := Compile(l, nil)
+	if err != nil {
+		t.Errorf("Failed %v", err)
+	}
+
+	if !bytes.Equal(compiled, result) {
+		t.Errorf("Failed %x", compiled)
+	}
+}
+
+func TestCompileReportsAllErrors(t *testing.T) {
+	script := "DUP - HASH160 BAD\nEQUAL 1.5 CHECKSIG"
+
+	l := new(lexer.Lexer)
+	l.Init(strings.NewReader(script), nil)
+
+	compiled, err := Compile(l, nil)
+	if err == nil || compiled != nil {
+		t.Errorf("Expected error got %x", compiled)
+	}
+
+	if l.ErrorCount() != 3 {
+		t.Errorf("Expected 3 errors got %v", l.Errors())
+	}
+}
+
+func TestBindings(t *testing.T) {
+	script := `DEFINE TIMEOUT 500000
+<expiry> CHECKLOCKTIMEVERIFY DROP TIMEOUT DROP
+DUP HASH160 <pubKeyHash> EQUALVERIFY CHECKSIG`
+
+	hash, _ := hex.DecodeString("010966776006953d5567439e5e39f86a0d273bee")
+	bindings := Bindings{
+		"expiry":     {0x01, 0x02, 0x03},
+		"pubKeyHash": hash,
+	}
+
+	l := new(lexer.Lexer)
+	l.Init(strings.NewReader(script), nil)
+
+	compiled, err := Compile(l, bindings)
+	if err != nil {
+		t.Fatalf("Failed %v", err)
+	}
+
+	result, _ := hex.DecodeString("03010203b17503" + "20a107" + "7576a914010966776006953d5567439e5e39f86a0d273bee88ac")
+	if !bytes.Equal(compiled, result) {
+		t.Errorf("Failed %x", compiled)
+	}
+
+	//Small values use the number operations so the result is a minimal push
+	tests := []struct {
+		value  string
+		script string
+	}{
+		{"", "00"},
+		{"05", "55"},
+		{"10", "60"},
+		{"81", "4f"},
+		{"11", "0111"},
+		{"0100", "020100"},
+	}
+
+	for _, test := range tests {
+		value, _ := hex.DecodeString(test.value)
+
+		l.Init(strings.NewReader("<value>"), nil)
+		compiled, err := Compile(l, Bindings{"value": value})
+		if err != nil || hex.EncodeToString(compiled) != test.script {
+			t.Errorf("Binding %s expected %s got %x %v", test.value, test.script, compiled, err)
+			continue
+		}
+
+		if !scanner.IsMinimalPush(compiled[0], value) {
+			t.Errorf("Binding %s compiled to non minimal %x", test.value, compiled)
+		}
+	}
+
+	l.Init(strings.NewReader(script), nil)
+	_, err = Compile(l, Bindings{"pubKeyHash": hash})
+	if err == nil || err.Error() != "Unbound Placeholders expiry" {
+		t.Errorf("Expected unbound placeholder error got %v", err)
+	}
+}
+
+func TestNullDataLiteral(t *testing.T) {
+	script := `RETURN "hello"`
+
+	l := new(lexer.Lexer)
+	l.Init(strings.NewReader(script), nil)
+
+	compiled, err := Compile(l, nil)
+	if err != nil || hex.EncodeToString(compiled) != "6a0568656c6c6f" {
+		t.Errorf("Failed %x %v", compiled, err)
+	}
+}
+
+func TestCompileBlock(t *testing.T) {
+	scripts := []string{
+		"",
+		"76a914010966776006953d5567439e5e39f86a0d273bee88ac",
+		"004f5160" + "0111" + "02e803",
+		"6351670052670068" + "64ab5168",
+		"ab76ab87",
+		"4c50" + strings.Repeat("aa", 80) + "4d0001" + strings.Repeat("bb", 256),
+	}
+
+	for _, script := range scripts {
+		expected, _ := hex.DecodeString(script)
+
+		s := new(scanner.Scanner)
+		s.Init(expected, nil)
+		block, err := ast.Parse(s)
+		if err != nil {
+			t.Errorf("%s Parse failed %v", script, err)
+			continue
+		}
+
+		compiled, err := CompileBlock(block)
+		if err != nil || !bytes.Equal(compiled, expected) {
+			t.Errorf("%s expected %x got %x %v", script, expected, compiled, err)
+		}
+	}
+
+	//Matches Compile for source
+	source := "DUP 0x0102 1000 -1 IF 1 ELSE CODESEPARATOR 16 ENDIF"
+
+	l := new(lexer.Lexer)
+	l.Init(strings.NewReader(source), nil)
+	expected, _ := Compile(l, nil)
+
+	l.Init(strings.NewReader(source), nil)
+	block, err := ast.Parse(l)
+	if err != nil {
+		t.Fatalf("Parse failed %v", err)
+	}
+
+	compiled, err := CompileBlock(block)
+	if err != nil || !bytes.Equal(compiled, expected) {
+		t.Errorf("Source expected %x got %x %v", expected, compiled, err)
+	}
+}
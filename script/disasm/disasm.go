@@ -0,0 +1,122 @@
+// Package disasm turns bytecode back into the source syntax accepted by lexer.Lexer.
+package disasm
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/spearson78/guardian/script/opcode"
+	"github.com/spearson78/guardian/script/scanner"
+	"github.com/spearson78/guardian/script/token"
+	"math"
+	"strings"
+)
+
+// Disassemble returns the source for script with one token per line and IF bodies indented.
+// Compiling the source reproduces script exactly, pushes that the compiler would encode differently
+// keep their PUSHDATA width and operations without a name are written as BYTECODE.
+// Scripts the scanner cannot read, such as truncated pushes, return an error.
+func Disassemble(script []byte) (string, error) {
+	var scanErr error
+
+	s := new(scanner.Scanner)
+	s.Init(script, func(pos int, msg string) {
+		if scanErr == nil {
+			scanErr = errors.New(fmt.Sprint(msg, " at ", pos))
+		}
+	})
+
+	var buffer bytes.Buffer
+	depth := 0
+
+	for tok := s.Scan(); tok != token.ENDOFSCRIPT; tok = s.Scan() {
+		var text string
+
+		switch tok {
+		case token.DATA:
+			text = pushData(s.ByteCode(), s.Data())
+		case token.NUMBER:
+			text = s.Number().String()
+		case token.OPERATION:
+			text = operation(s.Op())
+		case token.CODESEPARATOR:
+			text = "CODESEPARATOR"
+		case token.IF:
+			text = "IF"
+		case token.NOTIF:
+			text = "NOTIF"
+		case token.ELSE:
+			text = "ELSE"
+		case token.ENDIF:
+			text = "ENDIF"
+		default:
+			if scanErr == nil {
+				scanErr = errors.New(fmt.Sprint("Invalid Token at ", s.Pos()))
+			}
+		}
+
+		if scanErr != nil {
+			return "", scanErr
+		}
+
+		//ELSE and ENDIF line up with their IF
+		if (tok == token.ELSE || tok == token.ENDIF) && depth > 0 {
+			depth--
+		}
+
+		buffer.WriteString(strings.Repeat("\t", depth))
+		buffer.WriteString(text)
+		buffer.WriteString("\n")
+
+		if tok == token.IF || tok == token.NOTIF || tok == token.ELSE {
+			depth++
+		}
+	}
+
+	if scanErr != nil {
+		return "", scanErr
+	}
+
+	return buffer.String(), nil
+}
+
+func pushData(byteCode byte, data []byte) string {
+	l := len(data)
+
+	switch {
+	case byteCode == 0x00:
+		return "0"
+	case byteCode == 0x4c && l <= 75,
+		byteCode == 0x4d && l <= math.MaxUint8,
+		byteCode == 0x4e && l <= math.MaxUint16:
+		//The compiler would choose a narrower push
+		if l == 0 {
+			return "BYTECODE 0x" + hex.EncodeToString(emptyPush[byteCode])
+		}
+		return pushDataNames[byteCode] + " 0x" + hex.EncodeToString(data)
+	}
+
+	return "0x" + hex.EncodeToString(data)
+}
+
+var pushDataNames = map[byte]string{
+	0x4c: "PUSHDATA1",
+	0x4d: "PUSHDATA2",
+	0x4e: "PUSHDATA4",
+}
+
+// The lexer has no literal for empty data so these are written as bytecode.
+var emptyPush = map[byte][]byte{
+	0x4c: {0x4c, 0x00},
+	0x4d: {0x4d, 0x00, 0x00},
+	0x4e: {0x4e, 0x00, 0x00, 0x00, 0x00},
+}
+
+func operation(op opcode.OpCode) string {
+	if op.IsValid() && opcode.Parse(op.String()) == op {
+		return op.String()
+	}
+
+	return "BYTECODE 0x" + hex.EncodeToString([]byte{byte(op)})
+}
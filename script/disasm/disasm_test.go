@@ -0,0 +1,177 @@
+package disasm
+
+import (
+	"bytes"
+	"encoding/hex"
+	"github.com/spearson78/guardian/script/compiler"
+	"github.com/spearson78/guardian/script/lexer"
+	"github.com/spearson78/guardian/script/scanner"
+	"github.com/spearson78/guardian/script/token"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestStandardTransactionToBitcoinAddress(t *testing.T) {
+	script, _ := hex.DecodeString("76A91489ABCDEFABBAABBAABBAABBAABBAABBAABBAABBA88AC")
+
+	source, err := Disassemble(script)
+	if err != nil {
+		t.Fatalf("Failed %v", err)
+	}
+
+	expected := `DUP
+HASH160
+0x89abcdefabbaabbaabbaabbaabbaabbaabbaabba
+EQUALVERIFY
+CHECKSIG
+`
+
+	if source != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, source)
+	}
+}
+
+func TestDisassemble(t *testing.T) {
+	tests := []struct {
+		script string
+		source string
+	}{
+		{"", ""},
+		{"00", "0\n"},
+		{"4f510060", "-1\n1\n0\n16\n"},
+		{"0101", "0x01\n"},
+		{"4c0107", "PUSHDATA1 0x07\n"},
+		{"4c00", "BYTECODE 0x4c00\n"},
+		{"4d02000708", "PUSHDATA2 0x0708\n"},
+		{"4e0000000000", "BYTECODE 0x4e00000000\n0\n"},
+		{"4e0100000007", "PUSHDATA4 0x07\n"},
+		{"4d0001" + strings.Repeat("aa", 256), "0x" + strings.Repeat("aa", 256) + "\n"},
+		{"50b1b2", "RESERVED\nNOP2\nNOP3\n"},
+		{"bafdff", "BYTECODE 0xba\nBYTECODE 0xfd\nBYTECODE 0xff\n"},
+		{"5163516451675268676875ab76", "1\nIF\n\t1\n\tNOTIF\n\t\t1\n\tELSE\n\t\t2\n\tENDIF\nELSE\nENDIF\nDROP\nCODESEPARATOR\nDUP\n"},
+		{"6768", "ELSE\nENDIF\n"},
+	}
+
+	for _, test := range tests {
+		script, _ := hex.DecodeString(test.script)
+
+		source, err := Disassemble(script)
+		if err != nil {
+			t.Errorf("%s Failed %v", test.script, err)
+			continue
+		}
+
+		if source != test.source {
+			t.Errorf("%s expected %q got %q", test.script, test.source, source)
+		}
+	}
+}
+
+func TestInvalidScript(t *testing.T) {
+	tests := []string{
+		"02aa",
+		"4c",
+		"4c02aa",
+		"4d01",
+		"4e010000",
+	}
+
+	for _, test := range tests {
+		script, _ := hex.DecodeString(test)
+
+		_, err := Disassemble(script)
+		if err == nil {
+			t.Errorf("%s expected error", test)
+		}
+	}
+}
+
+func isValid(script []byte) bool {
+	s := new(scanner.Scanner)
+	s.Init(script, nil)
+	for tok := s.Scan(); tok != token.ENDOFSCRIPT; tok = s.Scan() {
+	}
+	return s.ErrorCount() == 0
+}
+
+func checkRoundTrip(t *testing.T, script []byte) {
+	source, err := Disassemble(script)
+	if err != nil {
+		if isValid(script) {
+			t.Fatalf("%x Failed %v", script, err)
+		}
+		return
+	}
+
+	l := new(lexer.Lexer)
+	l.Init(strings.NewReader(source), nil)
+
+	compiled, err := compiler.Compile(l, nil)
+	if err != nil {
+		t.Fatalf("%x Compile Failed %v\n%s", script, err, source)
+	}
+
+	if !bytes.Equal(compiled, script) {
+		t.Fatalf("%x round trip produced %x\n%s", script, compiled, source)
+	}
+}
+
+// randomScript builds a valid script from random pushes of every width and random opcode bytes.
+func randomScript(r *rand.Rand) []byte {
+	var script []byte
+
+	for n := r.Intn(16); n > 0; n-- {
+		data := make([]byte, r.Intn(300))
+		r.Read(data)
+
+		switch r.Intn(7) {
+		case 0:
+			script = append(script, byte(len(data)%76))
+			script = append(script, data[:len(data)%76]...)
+		case 1:
+			script = append(script, 0x4c, byte(len(data)))
+			script = append(script, data[:len(data)%256]...)
+		case 2:
+			script = append(script, 0x4d, byte(len(data)), byte(len(data)>>8))
+			script = append(script, data...)
+		case 3:
+			script = append(script, 0x4e, byte(len(data)), byte(len(data)>>8), 0x00, 0x00)
+			script = append(script, data...)
+		default:
+			//Any byte that is not a push
+			script = append(script, byte(0x4f+r.Intn(0xff-0x4f+1)))
+		}
+	}
+
+	return script
+}
+
+func TestRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 20000; i++ {
+		checkRoundTrip(t, randomScript(r))
+	}
+}
+
+func FuzzRoundTrip(f *testing.F) {
+	seeds := []string{
+		"",
+		"76a91489abcdefabbaabbaabbaabbaabbaabbaabbaabba88ac",
+		"4c0107",
+		"4c00",
+		"4d0100aa",
+		"516367686868",
+		"bafdff",
+	}
+
+	for _, seed := range seeds {
+		script, _ := hex.DecodeString(seed)
+		f.Add(script)
+	}
+
+	f.Fuzz(func(t *testing.T, script []byte) {
+		checkRoundTrip(t, script)
+	})
+}
@@ -7,49 +7,75 @@ import (
 var c0 = big.NewInt(0)
 var c1 = big.NewInt(1)
 
-//TODO:Arithmetic is only allowed on 4 byte integers consider a PopInt to validate the value on the stack is compatible
-
 func op_ONEADD(c *Context) error {
-	n := c.PopNumber()
+	n, err := c.PopNumber()
+	if err != nil {
+		return err
+	}
 	n.Add(n, c1)
 	c.PushNumber(n)
 	return nil
 }
 
 func op_ONESUB(c *Context) error {
-	n := c.PopNumber()
+	n, err := c.PopNumber()
+	if err != nil {
+		return err
+	}
 	n.Sub(n, c1)
 	c.PushNumber(n)
 	return nil
 }
 
 func op_NEGATE(c *Context) error {
-	n := c.PopNumber()
+	n, err := c.PopNumber()
+	if err != nil {
+		return err
+	}
 	n.Neg(n)
 	c.PushNumber(n)
 	return nil
 }
 
 func op_ABS(c *Context) error {
-	n := c.PopNumber()
+	n, err := c.PopNumber()
+	if err != nil {
+		return err
+	}
 	n.Abs(n)
 	c.PushNumber(n)
 	return nil
 }
 
 func op_NOT(c *Context) error {
-	c.PushBool(!c.PopBool())
+	n, err := c.PopNumber()
+	if err != nil {
+		return err
+	}
+
+	c.PushBool(n.Sign() == 0)
 	return nil
 }
 
 func op_ZERONOTEQUAL(c *Context) error {
-	c.PushBool(c.PopBool())
+	n, err := c.PopNumber()
+	if err != nil {
+		return err
+	}
+
+	c.PushBool(n.Sign() != 0)
 	return nil
 }
 
 func op_ADD(c *Context) error {
-	b := c.PopNumber()
-	a := c.PopNumber()
+	b, err := c.PopNumber()
+	if err != nil {
+		return err
+	}
+	a, err := c.PopNumber()
+	if err != nil {
+		return err
+	}
 
 	a.Add(a, b)
 
@@ -58,8 +84,14 @@ func op_ADD(c *Context) error {
 }
 
 func op_SUB(c *Context) error {
-	b := c.PopNumber()
-	a := c.PopNumber()
+	b, err := c.PopNumber()
+	if err != nil {
+		return err
+	}
+	a, err := c.PopNumber()
+	if err != nil {
+		return err
+	}
 
 	a.Sub(a, b)
 
@@ -68,24 +100,42 @@ func op_SUB(c *Context) error {
 }
 
 func op_BOOLAND(c *Context) error {
-	b := c.PopBool()
-	a := c.PopBool()
+	b, err := c.PopNumber()
+	if err != nil {
+		return err
+	}
+	a, err := c.PopNumber()
+	if err != nil {
+		return err
+	}
 
-	c.PushBool(a && b)
+	c.PushBool(a.Sign() != 0 && b.Sign() != 0)
 	return nil
 }
 
 func op_BOOLOR(c *Context) error {
-	b := c.PopBool()
-	a := c.PopBool()
+	b, err := c.PopNumber()
+	if err != nil {
+		return err
+	}
+	a, err := c.PopNumber()
+	if err != nil {
+		return err
+	}
 
-	c.PushBool(a || b)
+	c.PushBool(a.Sign() != 0 || b.Sign() != 0)
 	return nil
 }
 
 func op_NUMEQUAL(c *Context) error {
-	b := c.PopNumber()
-	a := c.PopNumber()
+	b, err := c.PopNumber()
+	if err != nil {
+		return err
+	}
+	a, err := c.PopNumber()
+	if err != nil {
+		return err
+	}
 
 	c.PushBool(a.Cmp(b) == 0)
 
@@ -97,8 +147,14 @@ func op_NUMEQUALVERIFY(c *Context) error {
 }
 
 func op_NUMNOTEQUAL(c *Context) error {
-	b := c.PopNumber()
-	a := c.PopNumber()
+	b, err := c.PopNumber()
+	if err != nil {
+		return err
+	}
+	a, err := c.PopNumber()
+	if err != nil {
+		return err
+	}
 
 	c.PushBool(a.Cmp(b) != 0)
 
@@ -106,8 +162,14 @@ func op_NUMNOTEQUAL(c *Context) error {
 }
 
 func op_LESSTHAN(c *Context) error {
-	b := c.PopNumber()
-	a := c.PopNumber()
+	b, err := c.PopNumber()
+	if err != nil {
+		return err
+	}
+	a, err := c.PopNumber()
+	if err != nil {
+		return err
+	}
 
 	c.PushBool(a.Cmp(b) < 0)
 
@@ -115,8 +177,14 @@ func op_LESSTHAN(c *Context) error {
 }
 
 func op_GREATERTHAN(c *Context) error {
-	b := c.PopNumber()
-	a := c.PopNumber()
+	b, err := c.PopNumber()
+	if err != nil {
+		return err
+	}
+	a, err := c.PopNumber()
+	if err != nil {
+		return err
+	}
 
 	c.PushBool(a.Cmp(b) > 0)
 
@@ -124,8 +192,14 @@ func op_GREATERTHAN(c *Context) error {
 }
 
 func op_LESSTHANOREQUAL(c *Context) error {
-	b := c.PopNumber()
-	a := c.PopNumber()
+	b, err := c.PopNumber()
+	if err != nil {
+		return err
+	}
+	a, err := c.PopNumber()
+	if err != nil {
+		return err
+	}
 
 	c.PushBool(a.Cmp(b) <= 0)
 
@@ -133,8 +207,14 @@ func op_LESSTHANOREQUAL(c *Context) error {
 }
 
 func op_GREATERTHANOREQUAL(c *Context) error {
-	b := c.PopNumber()
-	a := c.PopNumber()
+	b, err := c.PopNumber()
+	if err != nil {
+		return err
+	}
+	a, err := c.PopNumber()
+	if err != nil {
+		return err
+	}
 
 	c.PushBool(a.Cmp(b) >= 0)
 
@@ -142,8 +222,14 @@ func op_GREATERTHANOREQUAL(c *Context) error {
 }
 
 func op_MIN(c *Context) error {
-	b := c.PopNumber()
-	a := c.PopNumber()
+	b, err := c.PopNumber()
+	if err != nil {
+		return err
+	}
+	a, err := c.PopNumber()
+	if err != nil {
+		return err
+	}
 
 	if a.Cmp(b) > 0 {
 		c.PushNumber(b)
@@ -155,8 +241,14 @@ func op_MIN(c *Context) error {
 }
 
 func op_MAX(c *Context) error {
-	b := c.PopNumber()
-	a := c.PopNumber()
+	b, err := c.PopNumber()
+	if err != nil {
+		return err
+	}
+	a, err := c.PopNumber()
+	if err != nil {
+		return err
+	}
 
 	if a.Cmp(b) > 0 {
 		c.PushNumber(a)
@@ -168,17 +260,21 @@ func op_MAX(c *Context) error {
 }
 
 func op_WITHIN(c *Context) error {
-	max := c.PopNumber()
-	min := c.PopNumber()
-	x := c.PopNumber()
-
-	if x.Cmp(max) > 0 {
-		c.PushBool(false)
-	} else if x.Cmp(min) < 0 {
-		c.PushBool(false)
-	} else {
-		c.PushBool(true)
+	max, err := c.PopNumber()
+	if err != nil {
+		return err
+	}
+	min, err := c.PopNumber()
+	if err != nil {
+		return err
 	}
+	x, err := c.PopNumber()
+	if err != nil {
+		return err
+	}
+
+	//The range includes min but excludes max
+	c.PushBool(x.Cmp(min) >= 0 && x.Cmp(max) < 0)
 
 	return nil
 }
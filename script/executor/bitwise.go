@@ -5,8 +5,14 @@ import (
 )
 
 func op_EQUAL(c *Context) error {
-	a := c.Pop()
-	b := c.Pop()
+	a, err := c.Pop()
+	if err != nil {
+		return err
+	}
+	b, err := c.Pop()
+	if err != nil {
+		return err
+	}
 
 	c.PushBool(bytes.Equal(a, b))
 
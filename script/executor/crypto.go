@@ -8,15 +8,20 @@ import (
 	_ "crypto/sha1"
 	_ "crypto/sha256"
 	"errors"
-	"fmt"
+	"math/big"
 )
 
+var cMaxMultiSigKeys = big.NewInt(20)
+
 func op_RIPEMD160(c *Context) error {
 
-	top := c.Pop()
+	top, err := c.Pop()
+	if err != nil {
+		return err
+	}
 
 	h := crypto.RIPEMD160.New()
-	_, err := h.Write(top)
+	_, err = h.Write(top)
 	if err != nil {
 		return errors.New("Hash Failed")
 	}
@@ -30,10 +35,33 @@ func op_RIPEMD160(c *Context) error {
 
 func op_SHA1(c *Context) error {
 
-	top := c.Pop()
+	top, err := c.Pop()
+	if err != nil {
+		return err
+	}
 
 	h := crypto.SHA1.New()
-	_, err := h.Write(top)
+	_, err = h.Write(top)
+	if err != nil {
+		return errors.New("Hash Failed")
+	}
+
+	res := h.Sum(nil)
+
+	c.Push(res)
+
+	return nil
+}
+
+func op_SHA256(c *Context) error {
+
+	top, err := c.Pop()
+	if err != nil {
+		return err
+	}
+
+	h := crypto.SHA256.New()
+	_, err = h.Write(top)
 	if err != nil {
 		return errors.New("Hash Failed")
 	}
@@ -47,10 +75,13 @@ func op_SHA1(c *Context) error {
 
 func op_HASH160(c *Context) error {
 
-	top := c.Pop()
+	top, err := c.Pop()
+	if err != nil {
+		return err
+	}
 
 	h := crypto.SHA256.New()
-	_, err := h.Write(top)
+	_, err = h.Write(top)
 	if err != nil {
 		return errors.New("Hash Failed")
 	}
@@ -72,10 +103,13 @@ func op_HASH160(c *Context) error {
 
 func op_HASH256(c *Context) error {
 
-	top := c.Pop()
+	top, err := c.Pop()
+	if err != nil {
+		return err
+	}
 
 	h := sha256d.New()
-	_, err := h.Write(top)
+	_, err = h.Write(top)
 	if err != nil {
 		return errors.New("Hash Failed")
 	}
@@ -87,26 +121,51 @@ func op_HASH256(c *Context) error {
 	return nil
 }
 
+func checkSignature(c *Context, pk []byte, sig []byte, subScript []byte) error {
+	attempt := CheckSigAttempt{
+		PublicKey: pk,
+	}
+
+	if len(sig) == 0 {
+		attempt.Err = ErrEmptySignature
+	} else {
+		attempt.HashType = uint32(sig[len(sig)-1])
+		attempt.Signature = sig[:len(sig)-1]
+		attempt.Err = c.signatureCheck.CheckSig(pk, attempt.HashType, attempt.Signature, subScript)
+	}
+
+	if c.result != nil {
+		c.result.CheckSigs = append(c.result.CheckSigs, attempt)
+	}
+
+	return attempt.Err
+}
+
 func op_CHECKSIG(c *Context) error {
 	if c.signatureCheck == nil {
-		return errors.New("No SignatureCheck Implementation")
+		return ErrNoSignatureImplementation
 	}
 
-	pk := c.Pop()
-	sig := c.Pop()
+	pk, err := c.Pop()
+	if err != nil {
+		return err
+	}
+	sig, err := c.Pop()
+	if err != nil {
+		return err
+	}
 
 	subScript, err := Subscriptify(c.script[c.codeSeparatorPos:], sig)
 	if err != nil {
 		return err
 	}
 
-	hashType := uint32(sig[len(sig)-1])
-	sigVal := sig[:len(sig)-1]
-
-	err = c.signatureCheck.CheckSig(pk, hashType, sigVal, subScript)
+	err = c.checkEncoding(pk, sig)
 	if err != nil {
-		fmt.Println(err)
+		return err
 	}
+
+	err = checkSignature(c, pk, sig, subScript)
 	c.PushBool(err == nil)
 
 	return nil
@@ -117,21 +176,81 @@ func op_CHECKSIGVERIFY(c *Context) error {
 }
 
 func op_CHECKMULTISIG(c *Context) error {
+	if c.signatureCheck == nil {
+		return ErrNoSignatureImplementation
+	}
+
+	pkcount, err := c.PopNumber()
+	if err != nil {
+		return err
+	}
+	if pkcount.Sign() < 0 || pkcount.Cmp(cMaxMultiSigKeys) > 0 {
+		return ErrPubKeyCount
+	}
 
-	pkcount := int(c.PopNumber().Int64())
-	for i := 0; i < pkcount; i++ {
-		_ = c.Pop()
+	//Each public key counts towards the operation limit
+	err = c.addOps(int(pkcount.Int64()))
+	if err != nil {
+		return err
+	}
+
+	//Keys and signatures are held in the order they are popped, the last one pushed comes first.
+	//This matches the order in which the reference client pairs them up.
+	pks := make([][]byte, pkcount.Int64())
+	if c.Depth() < len(pks)+1 {
+		return ErrStackUnderflow
+	}
+	for i := range pks {
+		pks[i], _ = c.Pop()
+	}
+
+	sigcount, err := c.PopNumber()
+	if err != nil {
+		return err
 	}
-	sigcount := int(c.PopNumber().Int64())
-	for i := 0; i < sigcount; i++ {
-		_ = c.Pop()
+	if sigcount.Sign() < 0 || sigcount.Cmp(pkcount) > 0 {
+		return ErrSigCount
 	}
 
-	//Due to a bug in the reference client, one extra unused value is removed from the stack. 
-	_ = c.Pop()
+	sigs := make([][]byte, sigcount.Int64())
+	if c.Depth() < len(sigs)+1 {
+		return ErrStackUnderflow
+	}
+	for i := range sigs {
+		sigs[i], _ = c.Pop()
+	}
+
+	//Due to a bug in the reference client, one extra unused value is removed from the stack.
+	_, _ = c.Pop()
+
+	subScript, err := Subscriptify(c.script[c.codeSeparatorPos:], sigs...)
+	if err != nil {
+		return err
+	}
+
+	//Each signature must match a public key and the signatures must be in the same order as the keys.
+	//A key that fails to match the current signature is skipped and never considered again.
+	success := true
+	isig, ipk := 0, 0
+	for success && isig < len(sigs) {
+		//Encodings are only checked for the pairs that are evaluated
+		err = c.checkEncoding(pks[ipk], sigs[isig])
+		if err != nil {
+			return err
+		}
+
+		err = checkSignature(c, pks[ipk], sigs[isig], subScript)
+		if err == nil {
+			isig++
+		}
+		ipk++
+
+		if len(sigs)-isig > len(pks)-ipk {
+			success = false
+		}
+	}
 
-	//TODO: Implement the necessary checkmultisig callback
-	c.PushBool(false)
+	c.PushBool(success)
 
 	return nil
 }
@@ -3,11 +3,12 @@ package executor
 import (
 	"bytes"
 	"errors"
+	"github.com/spearson78/guardian/script/compiler"
 	"github.com/spearson78/guardian/script/scanner"
 	"github.com/spearson78/guardian/script/token"
 )
 
-func Subscriptify(script []byte, sig []byte) ([]byte, error) {
+func Subscriptify(script []byte, sigs ...[]byte) ([]byte, error) {
 
 	s := new(scanner.Scanner)
 	s.Init(script, nil)
@@ -16,13 +17,14 @@ func Subscriptify(script []byte, sig []byte) ([]byte, error) {
 
 loop:
 	for {
+		start := s.Pos()
 		tok := s.Scan()
 		switch tok {
 		case token.DATA:
-			//Suppress the signature
-			if !bytes.Equal(s.Data(), sig) {
-				subscript = append(subscript, s.ByteCode())
-				subscript = append(subscript, s.Data()...)
+			//Suppress the signatures, the push is copied including any length bytes
+			raw := script[start:s.Pos()]
+			if !isSignature(raw, sigs) {
+				subscript = append(subscript, raw...)
 			}
 		case token.CODESEPARATOR:
 			//Remove code separators
@@ -42,3 +44,15 @@ loop:
 
 	return subscript, nil
 }
+
+// isSignature reports whether push is the standard push of one of sigs.
+// Like the reference client only this exact encoding is removed, other pushes of the same data are kept.
+func isSignature(push []byte, sigs [][]byte) bool {
+	for _, sig := range sigs {
+		if bytes.Equal(push, compiler.AppendPushData(nil, sig)) {
+			return true
+		}
+	}
+
+	return false
+}
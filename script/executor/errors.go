@@ -0,0 +1,39 @@
+package executor
+
+import (
+	"errors"
+)
+
+var (
+	ErrStackUnderflow            = errors.New("Stack Underflow")
+	ErrInvalidStackOperation     = errors.New("Invalid Stack Operation")
+	ErrInvalidAltStackOperation  = errors.New("Invalid Alt Stack Operation")
+	ErrNoSignatureImplementation = errors.New("No SignatureCheck Implementation")
+	ErrEmptySignature            = errors.New("Empty Signature")
+	ErrReservedOpCode            = errors.New("Reserved OpCode")
+	ErrDisabledOpCode            = errors.New("Disabled OpCode")
+	ErrVerIf                     = errors.New("Invalid VERIF Or VERNOTIF")
+	ErrTracerUnsupported         = errors.New("Tracer Requires The AST Engine")
+
+	ErrScriptSize  = errors.New("Script Size Limit Exceeded")
+	ErrPushSize    = errors.New("Push Size Limit Exceeded")
+	ErrOpCount     = errors.New("Operation Count Limit Exceeded")
+	ErrStackSize   = errors.New("Stack Size Limit Exceeded")
+	ErrNumberSize  = errors.New("Numeric Operand Size Limit Exceeded")
+	ErrPubKeyCount = errors.New("Invalid Public Key Count")
+	ErrSigCount    = errors.New("Invalid Signature Count")
+
+	ErrMinimalData = errors.New("Non Minimal Data Push")
+
+	ErrSigDER      = errors.New("Non Canonical DER Signature")
+	ErrSigHighS    = errors.New("Signature S Value Above Half Curve Order")
+	ErrSigHashType = errors.New("Undefined Signature Hash Type")
+	ErrPubKeyType  = errors.New("Invalid Public Key Encoding")
+
+	ErrEvalFalse   = errors.New("Script Evaluated To False")
+	ErrSigPushOnly = errors.New("Signature Script Is Not Push Only")
+
+	ErrNoTransaction       = errors.New("No Transaction")
+	ErrNegativeLockTime    = errors.New("Negative LockTime")
+	ErrUnsatisfiedLockTime = errors.New("Unsatisfied LockTime")
+)
@@ -1,9 +1,10 @@
 package executor
 
 import (
-	"errors"
 	"github.com/spearson78/guardian/encoding/scriptint"
 	"github.com/spearson78/guardian/script/opcode"
+	"github.com/spearson78/guardian/script/scanner"
+	"github.com/spearson78/guardian/transaction"
 	"math/big"
 )
 
@@ -18,12 +19,22 @@ type Context struct {
 	script           []byte
 	codeSeparatorPos int
 	signatureCheck   SignatureCheck
+	opCount          int
+
+	tx         *transaction.Tx
+	inputIndex int
+
+	result *ExecutionResult
+
+	//Execute uses DefaultLimits when left as the zero value
+	Limits Limits
+	Flags  VerifyFlags
 }
 
 type OpCodeImplementation func(context *Context) error
 
 func op_DISABLED(c *Context) error {
-	return errors.New("DISABLED OPCODE")
+	return ErrDisabledOpCode
 }
 
 func compositeOp(c *Context, ops ...OpCodeImplementation) error {
@@ -37,12 +48,21 @@ func compositeOp(c *Context, ops ...OpCodeImplementation) error {
 	return nil
 }
 
-var opCodeImpls = [...]OpCodeImplementation{
+// Bytes without an implementation are undefined opcodes which fail when executed.
+var opCodeImpls = [256]OpCodeImplementation{
 	//Flow
 	opcode.NOP:    op_NOP,
 	opcode.VERIFY: op_VERIFY,
 	opcode.RETURN: op_RETURN,
 
+	//Reserved
+	opcode.RESERVED:  op_RESERVED,
+	opcode.VER:       op_RESERVED,
+	opcode.VERIF:     op_VERIF,
+	opcode.VERNOTIF:  op_VERIF,
+	opcode.RESERVED1: op_RESERVED,
+	opcode.RESERVED2: op_RESERVED,
+
 	//Stack
 	opcode.TOALTSTACK:   op_TOALTSTACK,
 	opcode.FROMALTSTACK: op_FROMALTSTACK,
@@ -69,7 +89,7 @@ var opCodeImpls = [...]OpCodeImplementation{
 	opcode.SUBSTR: op_DISABLED,
 	opcode.LEFT:   op_DISABLED,
 	opcode.RIGHT:  op_DISABLED,
-	opcode.SIZE:   op_DISABLED,
+	opcode.SIZE:   op_SIZE,
 
 	//Bitwise
 	opcode.INVERT:      op_DISABLED,
@@ -111,6 +131,7 @@ var opCodeImpls = [...]OpCodeImplementation{
 	//Crypto
 	opcode.RIPEMD160:           op_RIPEMD160,
 	opcode.SHA1:                op_SHA1,
+	opcode.SHA256:              op_SHA256,
 	opcode.HASH160:             op_HASH160,
 	opcode.HASH256:             op_HASH256,
 	opcode.CHECKSIG:            op_CHECKSIG,
@@ -120,8 +141,8 @@ var opCodeImpls = [...]OpCodeImplementation{
 
 	//Reserved NOPs
 	opcode.NOP1:  op_NOP,
-	opcode.NOP2:  op_NOP,
-	opcode.NOP3:  op_NOP,
+	opcode.NOP2:  op_CHECKLOCKTIMEVERIFY,
+	opcode.NOP3:  op_CHECKSEQUENCEVERIFY,
 	opcode.NOP4:  op_NOP,
 	opcode.NOP5:  op_NOP,
 	opcode.NOP6:  op_NOP,
@@ -131,12 +152,36 @@ var opCodeImpls = [...]OpCodeImplementation{
 	opcode.NOP10: op_NOP,
 }
 
+// Engine selects how an Executor runs a script, both engines implement the same rules.
+type Engine int
+
+const (
+	//Parses the script into an ast.Block before visiting it, supports Tracer
+	AstEngine Engine = iota
+	//Executes the bytecode as it is scanned in the same way as the reference client, does not support Tracer
+	ScannerEngine
+)
+
 type Executor struct {
 	Context
+
+	Engine Engine
+
+	//Optional, receives every node executed, Execute fails with ErrTracerUnsupported for the ScannerEngine
+	Tracer   Tracer
+	startPos map[int]int
 }
 
+// Init prepares the Executor for use with DefaultLimits, the limits may be changed before calling Execute.
 func (this *Executor) Init(signatureCheck SignatureCheck) {
 	this.Context.signatureCheck = signatureCheck
+	this.Context.Limits = DefaultLimits
+}
+
+// SetTx supplies the transaction and input being validated to the lock time operations.
+func (this *Executor) SetTx(tx *transaction.Tx, inputIndex int) {
+	this.Context.tx = tx
+	this.Context.inputIndex = inputIndex
 }
 
 func (this *Context) Push(data []byte) {
@@ -151,9 +196,9 @@ func (this *Context) AltPush(data []byte) {
 	this.altstack = append(this.altstack, data)
 }
 
-func (this *Context) AltPop() []byte {
+func (this *Context) AltPop() ([]byte, error) {
 	if len(this.altstack) == 0 {
-		panic("Alt Stack Underflow")
+		return nil, ErrInvalidAltStackOperation
 	}
 
 	top := this.altstack[len(this.altstack)-1]
@@ -163,28 +208,32 @@ func (this *Context) AltPop() []byte {
 		this.altstack = this.altstack[:len(this.altstack)-1]
 	}
 
-	return top
+	return top, nil
 }
 
-func (this *Context) Peek() []byte {
-	if len(this.stack) == 0 {
-		return nil
-	}
-
-	return this.stack[len(this.stack)-1]
+func (this *Context) Peek() ([]byte, error) {
+	return this.PeekN(0)
 }
 
-func (this *Context) PeekN(n int) []byte {
+func (this *Context) PeekN(n int) ([]byte, error) {
+	if n < 0 {
+		return nil, ErrInvalidStackOperation
+	}
+
 	if len(this.stack) <= n {
-		return nil
+		return nil, ErrStackUnderflow
 	}
 
-	return this.stack[len(this.stack)-1-n]
+	return this.stack[len(this.stack)-1-n], nil
 }
 
-func (this *Context) PopN(n int) []byte {
+func (this *Context) PopN(n int) ([]byte, error) {
+	if n < 0 {
+		return nil, ErrInvalidStackOperation
+	}
+
 	if len(this.stack) <= n {
-		return nil
+		return nil, ErrStackUnderflow
 	}
 
 	xn := this.stack[len(this.stack)-1-n]
@@ -194,15 +243,47 @@ func (this *Context) PopN(n int) []byte {
 
 	this.stack = append(poppedStack, remainingStack...)
 
-	return xn
+	return xn, nil
 }
 
-func (this *Context) PopNumber() *big.Int {
-	return scriptint.Decode(this.Pop())
+func (this *Context) PopNumber() (*big.Int, error) {
+	top, err := this.Pop()
+	if err != nil {
+		return nil, err
+	}
+
+	return this.decodeNumber(top, this.Limits.MaxNumberSize)
 }
 
-func (this *Context) PopBool() bool {
-	return scriptint.Decode(this.Pop()).Sign() != 0
+// decodeNumber applies the size limit and, with VerifyMinimalData, the minimal encoding rule to a numeric operand.
+func (this *Context) decodeNumber(b []byte, maxLen int) (*big.Int, error) {
+	if len(b) > maxLen {
+		return nil, ErrNumberSize
+	}
+
+	if this.Flags&VerifyMinimalData != 0 {
+		return scriptint.DecodeMinimal(b, maxLen)
+	}
+
+	return scriptint.Decode(b), nil
+}
+
+// checkPush enforces VerifyMinimalData for a push about to be executed.
+func (this *Context) checkPush(byteCode byte, data []byte) error {
+	if this.Flags&VerifyMinimalData != 0 && !scanner.IsMinimalPush(byteCode, data) {
+		return ErrMinimalData
+	}
+
+	return nil
+}
+
+func (this *Context) PopBool() (bool, error) {
+	top, err := this.Pop()
+	if err != nil {
+		return false, err
+	}
+
+	return scriptint.Decode(top).Sign() != 0, nil
 }
 
 func (this *Context) PushNumber(n *big.Int) {
@@ -217,9 +298,9 @@ func (this *Context) PushBool(b bool) {
 	}
 }
 
-func (this *Context) Pop() []byte {
+func (this *Context) Pop() ([]byte, error) {
 	if len(this.stack) == 0 {
-		panic("Stack Underflow")
+		return nil, ErrStackUnderflow
 	}
 
 	top := this.stack[len(this.stack)-1]
@@ -229,15 +310,36 @@ func (this *Context) Pop() []byte {
 		this.stack = this.stack[:len(this.stack)-1]
 	}
 
-	return top
+	return top, nil
 }
 
-//Must use []byte as checksig needs access to the script
-func (this *Executor) Execute(script []byte) error {
+// Must use []byte as checksig needs access to the script
+// The stack is kept between calls but the alt stack starts empty for every script.
+// The result is returned even when execution fails so the reason for a failure can be investigated.
+func (this *Executor) Execute(script []byte) (*ExecutionResult, error) {
 
 	this.codeSeparatorPos = 0
 	this.script = script
+	this.altstack = nil
+	this.result = new(ExecutionResult)
+
+	if this.Engine == ScannerEngine && this.Tracer != nil {
+		return this.result, ErrTracerUnsupported
+	}
+
+	if this.Limits == (Limits{}) {
+		this.Limits = DefaultLimits
+	}
+
+	opCount, err := CheckScriptLimits(script, this.Limits)
+	if err != nil {
+		return this.result, err
+	}
+	this.opCount = opCount
+
+	if this.Engine == ScannerEngine {
+		return this.result, this.execScanner(script)
+	}
 
-	//return this.execScanner(script)
-	return this.execAst(script)
+	return this.result, this.execAst(script)
 }
@@ -7,6 +7,23 @@ import (
 )
 
 func (this *Executor) Visit(node ast.Node) (bool, error) {
+	if this.Tracer == nil {
+		return this.visit(node)
+	}
+
+	step := this.newStep(node)
+	this.Tracer.Before(step)
+
+	cont, err := this.visit(node)
+
+	after := *step
+	this.updateStep(&after)
+	this.Tracer.After(&after, err)
+
+	return cont, err
+}
+
+func (this *Executor) visit(node ast.Node) (bool, error) {
 
 	switch n := node.(type) {
 	case *ast.CodeSeparator:
@@ -27,25 +44,45 @@ func (this *Executor) Visit(node ast.Node) (bool, error) {
 		}
 
 	case *ast.Data:
+		if this.Flags&VerifyMinimalData != 0 {
+			//The tree does not keep the push operation so it is read from the script
+			err := this.checkPush(this.script[this.startPos[n.DataPos]], n.Value)
+			if err != nil {
+				return false, err
+			}
+		}
+
 		this.Push(n.Value)
 	case *ast.Number:
 		this.PushNumber(n.Value)
 	case *ast.IfStmt:
-		top := this.PopBool()
+		top, err := this.PopBool()
+		if err != nil {
+			return false, err
+		}
 
-		if top != n.Not {
-			return true, n.Body.ForEachNode(this)
-		} else {
-			return true, n.Else.ForEachNode(this)
+		for _, branch := range n.Branches(top != n.Not) {
+			err = branch.ForEachNode(this)
+			if err != nil {
+				return false, err
+			}
 		}
 	default:
 		return false, errors.New("Unknown Node Type - " + n.String())
 	}
 
+	if err := this.checkStackSize(); err != nil {
+		return false, err
+	}
+
 	return true, nil
 }
 
 func (this *Executor) execAst(script []byte) error {
+	if this.Tracer != nil || this.Flags&VerifyMinimalData != 0 {
+		this.indexStartPos(script)
+	}
+
 	s := new(scanner.Scanner)
 	s.Init(script, nil)
 	block, err := ast.Parse(s)
@@ -53,13 +90,18 @@ func (this *Executor) execAst(script []byte) error {
 		return err
 	}
 
+	err = validate(block)
+	if err != nil {
+		return err
+	}
+
 	err = block.ForEachNode(this)
 	if err != nil {
 		return err
 	}
 
 	if s.ErrorCount() != 0 {
-		return errors.New("TokenSource reported errors")
+		return ast.ErrTokenSourceError
 	}
 
 	return nil
@@ -0,0 +1,181 @@
+package executor
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+)
+
+var fuzzSeeds = []string{
+	"",
+	"76a91489abcdefabbaabbaabbaabbaabbaabbaabbaabba88ac",
+	"0051ae",
+	"516367686868",
+	"67",
+	"68",
+	"4c",
+	"4d01",
+	"4e010000",
+	"4bff",
+	"00637568",
+	"6c",
+	"5179",
+	"4f79",
+	"527a",
+	"ac",
+	"00ac",
+	"0000ae",
+	"5f5faf",
+	"ab51ab",
+}
+
+func executeNoPanic(t *testing.T, script []byte) {
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("Execute %s panicked %v", hex.EncodeToString(script), r)
+		}
+	}()
+
+	var tracer TableTracer
+	tracer.Init(ioutil.Discard)
+
+	e := new(Executor)
+	e.Init(new(MockCheckSig))
+	e.Tracer = &tracer
+	e.Execute(script)
+	tracer.Flush()
+}
+
+func FuzzExecute(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		script, _ := hex.DecodeString(seed)
+		f.Add(script)
+	}
+
+	f.Fuzz(func(t *testing.T, script []byte) {
+		executeNoPanic(t, script)
+	})
+}
+
+func TestExecuteRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 20000; i++ {
+		script := make([]byte, r.Intn(64))
+		r.Read(script)
+		executeNoPanic(t, script)
+	}
+}
+
+type engineResult struct {
+	err       error
+	stack     [][]byte
+	altstack  [][]byte
+	checkSigs int
+}
+
+func runEngine(t *testing.T, engine Engine, flags VerifyFlags, script []byte) engineResult {
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("Execute %s engine %v panicked %v", hex.EncodeToString(script), engine, r)
+		}
+	}()
+
+	e := new(Executor)
+	e.Init(new(MockCheckSig))
+	e.Engine = engine
+	e.Flags = flags
+	result, err := e.Execute(script)
+
+	return engineResult{
+		err:       err,
+		stack:     e.stack,
+		altstack:  e.altstack,
+		checkSigs: len(result.CheckSigs),
+	}
+}
+
+func equalStacks(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// compareEngines runs script through both engines and requires identical errors and stacks.
+func compareEngines(t *testing.T, script []byte) {
+	for _, flags := range []VerifyFlags{VerifyNone, VerifyMinimalData} {
+		compareEnginesWithFlags(t, flags, script)
+	}
+}
+
+func compareEnginesWithFlags(t *testing.T, flags VerifyFlags, script []byte) {
+	a := runEngine(t, AstEngine, flags, script)
+	s := runEngine(t, ScannerEngine, flags, script)
+
+	if fmt.Sprint(a.err) != fmt.Sprint(s.err) {
+		t.Fatalf("Engines disagree on %s error ast %v scanner %v", hex.EncodeToString(script), a.err, s.err)
+	}
+
+	if !equalStacks(a.stack, s.stack) || !equalStacks(a.altstack, s.altstack) {
+		t.Fatalf("Engines disagree on %s stacks ast %x %x scanner %x %x", hex.EncodeToString(script), a.stack, a.altstack, s.stack, s.altstack)
+	}
+
+	if a.checkSigs != s.checkSigs {
+		t.Fatalf("Engines disagree on %s signature checks ast %v scanner %v", hex.EncodeToString(script), a.checkSigs, s.checkSigs)
+	}
+}
+
+// Fragments favouring conditionals and operations that succeed on small numbers.
+var engineFragments = []string{
+	"00", "4f", "51", "52", "53", "60", "01aa", "0280ff", "4c0100", "0101", "0100", "020100",
+	"63", "64", "67", "68", "63", "64", "67", "68",
+	"61", "69", "6a", "6b", "6c", "73", "74", "75", "76", "77", "78", "79", "7a", "7b", "7c", "7d",
+	"6d", "6e", "6f", "70", "71", "72", "82", "87", "88",
+	"8b", "8c", "8f", "90", "91", "92", "93", "94", "9a", "9b", "9c", "9d", "9e", "9f", "a0", "a1", "a2", "a3", "a4", "a5",
+	"a6", "a7", "a8", "a9", "aa", "ab", "ac", "ad", "ae", "af", "b0", "b1", "b9",
+	"50", "62", "65", "7e", "ba", "ff",
+}
+
+func randomEngineScript(r *rand.Rand) []byte {
+	var script []byte
+	for n := r.Intn(32); n > 0; n-- {
+		fragment, _ := hex.DecodeString(engineFragments[r.Intn(len(engineFragments))])
+		script = append(script, fragment...)
+	}
+
+	return script
+}
+
+func FuzzEngines(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		script, _ := hex.DecodeString(seed)
+		f.Add(script)
+	}
+
+	f.Fuzz(func(t *testing.T, script []byte) {
+		compareEngines(t, script)
+	})
+}
+
+func TestEnginesRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 20000; i++ {
+		compareEngines(t, randomEngineScript(r))
+
+		script := make([]byte, r.Intn(64))
+		r.Read(script)
+		compareEngines(t, script)
+	}
+}
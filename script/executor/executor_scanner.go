@@ -6,121 +6,75 @@ import (
 	"github.com/spearson78/guardian/script/token"
 )
 
-func seekEndIf(s *scanner.Scanner) error {
-	ifDepth := 0
-loop:
-	for {
-
-		tok := s.Scan()
-		switch tok {
-		case token.ENDOFSCRIPT:
-			return errors.New("Unclosed IF Statements")
-		case token.IF:
-			ifDepth++
-		case token.ENDIF:
-			if ifDepth == 0 {
-				break loop
-			} else {
-				ifDepth--
-			}
-		}
+// execScanner executes the bytecode directly in the same way as the reference client.
+// exec holds one entry per open IF, operations are only executed while every entry is true.
+func (this *Executor) execScanner(script []byte) error {
+	err := validateScript(script)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	s := new(scanner.Scanner)
+	s.Init(script, nil)
 
-}
+	var exec []bool
 
-func seekElse(s *scanner.Scanner) error {
-	ifDepth := 0
-loop:
-	for {
+	for tok := s.Scan(); tok != token.ENDOFSCRIPT; tok = s.Scan() {
 
-		tok := s.Scan()
-		switch tok {
-		case token.ENDOFSCRIPT:
-			return errors.New("Unclosed IF Statements")
-		case token.IF:
-			ifDepth++
-		case token.ENDIF:
-			if ifDepth == 0 {
-				break loop
-			} else {
-				ifDepth--
-			}
-		case token.ELSE:
-			if ifDepth == 0 {
-				break loop
-			}
+		executing := true
+		for _, e := range exec {
+			executing = executing && e
 		}
-	}
 
-	return nil
-}
-
-func (this *Executor) execScanner(script []byte) error {
-	s := new(scanner.Scanner)
-	s.Init(script, nil)
-
-	ifDepth := 0
-
-loop:
-	for {
-		tok := s.Scan()
 		switch tok {
-		case token.DATA:
-			this.Push(s.Data())
-		case token.OPERATION:
-			impl := opCodeImpls[s.Op()]
-			if impl == nil {
-				return errors.New("Unknown OpCode - " + s.Op().String())
+		case token.IF, token.NOTIF:
+			value := false
+			if executing {
+				top, err := this.PopBool()
+				if err != nil {
+					return err
+				}
+				value = top != (tok == token.NOTIF)
 			}
+			exec = append(exec, value)
+		case token.ELSE:
+			exec[len(exec)-1] = !exec[len(exec)-1]
+		case token.ENDIF:
+			exec = exec[:len(exec)-1]
+		case token.DATA:
+			if executing {
+				err := this.checkPush(s.ByteCode(), s.Data())
+				if err != nil {
+					return err
+				}
 
-			err := impl(&this.Context)
-			if err != nil {
-				return err
+				this.Push(s.Data())
 			}
-		case token.ENDOFSCRIPT:
-			break loop
 		case token.NUMBER:
-			this.PushNumber(s.Number())
-
-		case token.CODESEPARATOR:
-			this.codeSeparatorPos = s.Pos()
-		case token.IF:
-			ifDepth++
-			top := this.PopBool()
-			if !top {
-				seekElse(s)
+			if executing {
+				this.PushNumber(s.Number())
 			}
-		case token.NOTIF:
-			ifDepth++
-			top := this.PopBool()
-			if top {
-				seekElse(s)
-			}
-		case token.ELSE:
-			if ifDepth == 0 {
-				return errors.New("Unexpected EndIf")
+		case token.CODESEPARATOR:
+			if executing {
+				this.codeSeparatorPos = s.Pos()
 			}
-			ifDepth--
-			seekEndIf(s)
-		case token.ENDIF:
-			if ifDepth == 0 {
-				return errors.New("Unexpected Else")
+		case token.OPERATION:
+			if executing {
+				impl := opCodeImpls[s.Op()]
+				if impl == nil {
+					return errors.New("Unknown OpCode - " + s.Op().String())
+				}
+
+				err := impl(&this.Context)
+				if err != nil {
+					return err
+				}
 			}
-			ifDepth--
-		case token.INVALID:
-			return errors.New("Invalid Token")
-
 		}
-	}
 
-	if ifDepth != 0 {
-		return errors.New("Unclosed If")
-	}
-
-	if s.ErrorCount() != 0 {
-		return errors.New("TokenSource reported errors")
+		if err := this.checkStackSize(); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -3,9 +3,16 @@ package executor
 import (
 	"bytes"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"github.com/spearson78/guardian/crypto/secp256k1"
+	"github.com/spearson78/guardian/encoding/scriptint"
 	"github.com/spearson78/guardian/script/compiler"
 	"github.com/spearson78/guardian/script/lexer"
+	"github.com/spearson78/guardian/script/opcode"
+	"github.com/spearson78/guardian/transaction"
+	"io/ioutil"
+	"math/big"
 	"strings"
 	"testing"
 )
@@ -55,16 +62,16 @@ CHECKSIG
 
 	l := new(lexer.Lexer)
 	l.Init(strings.NewReader(script), nil)
-	compiled, _ := compiler.Compile(l)
+	compiled, _ := compiler.Compile(l, nil)
 
 	l.Init(strings.NewReader(subscript), nil)
-	compiledSubScript, _ := compiler.Compile(l)
+	compiledSubScript, _ := compiler.Compile(l, nil)
 
 	var checkSig MockCheckSig
 	e := new(Executor)
 	e.Init(&checkSig)
 
-	err := e.Execute(compiled)
+	_, err := e.Execute(compiled)
 	if err != nil {
 		t.Errorf("TestStandardTransactionToBitcoinAddressNoCheckSig Failed %v", err)
 	}
@@ -125,21 +132,977 @@ func TestIf(t *testing.T) {
 	for _, test := range tests {
 		l := new(lexer.Lexer)
 		l.Init(strings.NewReader(test.script), nil)
-		compiled, _ := compiler.Compile(l)
+		compiled, _ := compiler.Compile(l, nil)
 
 		e := new(Executor)
 		e.Init(nil)
 
-		e.Execute(compiled)
+		_, err := e.Execute(compiled)
+		if err != nil {
+			t.Errorf("TestIf %s Failed %v", test.script, err)
+			continue
+		}
 
-		top := e.Pop()
-		if top[0] != test.result {
+		top, err := e.Pop()
+		if err != nil || top[0] != test.result {
 			t.Errorf("Wrong Top %d", top[0])
 		}
 	}
 
 }
 
+type MockMultiCheckSig struct {
+	Valid     map[string]string
+	Checked   int
+	SubScript []byte
+}
+
+func (this *MockMultiCheckSig) CheckSig(pk []byte, hashType uint32, sig []byte, subScript []byte) error {
+	this.Checked++
+	this.SubScript = subScript
+
+	if this.Valid[hex.EncodeToString(pk)] != hex.EncodeToString(sig) {
+		return errors.New("Signature Mismatch")
+	}
+
+	return nil
+}
+
+func TestCheckMultiSig(t *testing.T) {
+
+	valid := map[string]string{
+		"02aa": "30aa",
+		"02bb": "30bb",
+		"02cc": "30cc",
+	}
+
+	tests := []struct {
+		script  string
+		result  bool
+		checked int
+	}{
+		{
+			script:  "0 0x30aa01 0x30bb01 2 0x02aa 0x02bb 0x02cc 3 CHECKMULTISIG",
+			result:  true,
+			checked: 3,
+		},
+		{
+			script:  "0 0x30aa01 0x30cc01 2 0x02aa 0x02bb 0x02cc 3 CHECKMULTISIG",
+			result:  true,
+			checked: 3,
+		},
+		{
+			script:  "0 0x30bb01 0x30aa01 2 0x02aa 0x02bb 0x02cc 3 CHECKMULTISIG",
+			result:  false,
+			checked: 2,
+		},
+		{
+			script:  "0 0x30dd01 1 0x02aa 0x02bb 2 CHECKMULTISIG",
+			result:  false,
+			checked: 2,
+		},
+		{
+			script:  "0 0 0x02aa 1 CHECKMULTISIG",
+			result:  true,
+			checked: 0,
+		},
+		{
+			script:  "0 0 0 CHECKMULTISIG",
+			result:  true,
+			checked: 0,
+		},
+	}
+
+	for _, test := range tests {
+		l := new(lexer.Lexer)
+		l.Init(strings.NewReader(test.script), nil)
+		compiled, _ := compiler.Compile(l, nil)
+
+		checkSig := MockMultiCheckSig{Valid: valid}
+		e := new(Executor)
+		e.Init(&checkSig)
+
+		_, err := e.Execute(compiled)
+		if err != nil {
+			t.Errorf("TestCheckMultiSig %s Failed %v", test.script, err)
+			continue
+		}
+
+		if e.Depth() != 1 {
+			t.Errorf("TestCheckMultiSig %s Wrong Depth %d", test.script, e.Depth())
+			continue
+		}
+
+		if result, _ := e.PopBool(); result != test.result {
+			t.Errorf("TestCheckMultiSig %s Wrong Result", test.script)
+		}
+
+		if checkSig.Checked != test.checked {
+			t.Errorf("TestCheckMultiSig %s Wrong Check Count %d", test.script, checkSig.Checked)
+		}
+
+		if bytes.Contains(checkSig.SubScript, []byte{0x30}) {
+			t.Errorf("TestCheckMultiSig %s Signatures Not Removed %s", test.script, hex.EncodeToString(checkSig.SubScript))
+		}
+	}
+}
+
+func TestCheckMultiSigUnderflow(t *testing.T) {
+
+	tests := []string{
+		"CHECKMULTISIG",
+		"0x02aa 0x02bb 3 CHECKMULTISIG",
+		"0x30aa01 1 0x02aa 1 CHECKMULTISIG",
+		"0 2 0x02aa 1 CHECKMULTISIG",
+		"0 0 21 CHECKMULTISIG",
+	}
+
+	for _, test := range tests {
+		l := new(lexer.Lexer)
+		l.Init(strings.NewReader(test), nil)
+		compiled, _ := compiler.Compile(l, nil)
+
+		e := new(Executor)
+		e.Init(new(MockMultiCheckSig))
+
+		_, err := e.Execute(compiled)
+		if err == nil {
+			t.Errorf("TestCheckMultiSigUnderflow %s Expected Error", test)
+		}
+	}
+}
+
+func TestStackErrors(t *testing.T) {
+
+	tests := []struct {
+		script string
+		err    error
+	}{
+		{"DROP", ErrStackUnderflow},
+		{"DUP", ErrStackUnderflow},
+		{"1 NIP", ErrStackUnderflow},
+		{"1 OVER", ErrStackUnderflow},
+		{"1 2 ROT", ErrStackUnderflow},
+		{"1 SWAP", ErrStackUnderflow},
+		{"1 TUCK", ErrStackUnderflow},
+		{"1 TWODROP", ErrStackUnderflow},
+		{"1 TWODUP", ErrStackUnderflow},
+		{"1 2 THREEDUP", ErrStackUnderflow},
+		{"1 2 3 TWOOVER", ErrStackUnderflow},
+		{"1 2 3 4 5 TWOROT", ErrStackUnderflow},
+		{"1 2 3 TWOSWAP", ErrStackUnderflow},
+		{"IFDUP", ErrStackUnderflow},
+		{"PICK", ErrStackUnderflow},
+		{"1 2 2 PICK", ErrInvalidStackOperation},
+		{"1 -1 PICK", ErrInvalidStackOperation},
+		{"1 2 ROLL", ErrInvalidStackOperation},
+		{"TOALTSTACK", ErrStackUnderflow},
+		{"FROMALTSTACK", ErrInvalidAltStackOperation},
+		{"1 ADD", ErrStackUnderflow},
+		{"NOT", ErrStackUnderflow},
+		{"SIZE", ErrStackUnderflow},
+		{"NEGATE", ErrStackUnderflow},
+		{"1 2 WITHIN", ErrStackUnderflow},
+		{"1 EQUAL", ErrStackUnderflow},
+		{"VERIFY", ErrStackUnderflow},
+		{"HASH160", ErrStackUnderflow},
+		{"SHA1", ErrStackUnderflow},
+		{"1 CHECKSIG", ErrStackUnderflow},
+		{"IF ENDIF", ErrStackUnderflow},
+		{"NOTIF ELSE ENDIF", ErrStackUnderflow},
+	}
+
+	for _, test := range tests {
+		l := new(lexer.Lexer)
+		l.Init(strings.NewReader(test.script), nil)
+		compiled, err := compiler.Compile(l, nil)
+		if err != nil {
+			t.Errorf("TestStackErrors %s Compile Failed %v", test.script, err)
+			continue
+		}
+
+		e := new(Executor)
+		e.Init(new(MockCheckSig))
+
+		_, err = e.Execute(compiled)
+		if err != test.err {
+			t.Errorf("TestStackErrors %s expected %v got %v", test.script, test.err, err)
+		}
+	}
+}
+
+func TestEmptySignature(t *testing.T) {
+	l := new(lexer.Lexer)
+	l.Init(strings.NewReader("0 0x02aa CHECKSIG"), nil)
+	compiled, _ := compiler.Compile(l, nil)
+
+	var checkSig MockCheckSig
+	e := new(Executor)
+	e.Init(&checkSig)
+
+	_, err := e.Execute(compiled)
+	if err != nil {
+		t.Errorf("TestEmptySignature Failed %v", err)
+	}
+
+	if result, err := e.PopBool(); err != nil || result {
+		t.Errorf("TestEmptySignature expected false")
+	}
+
+	if checkSig.Pk != nil {
+		t.Errorf("TestEmptySignature SignatureCheck should not be called")
+	}
+}
+
+func TestExecutionResult(t *testing.T) {
+	l := new(lexer.Lexer)
+	l.Init(strings.NewReader("0x30aa01 0x02aa CHECKSIG 0 0x30bb02 0x30ff03 2 0x02aa 0x02bb 0x02cc 3 CHECKMULTISIG 0 0x02aa CHECKSIG"), nil)
+	compiled, _ := compiler.Compile(l, nil)
+
+	checkSig := &MockMultiCheckSig{
+		Valid: map[string]string{
+			"02aa": "30aa",
+			"02bb": "30bb",
+		},
+	}
+
+	e := new(Executor)
+	e.Init(checkSig)
+
+	result, err := e.Execute(compiled)
+	if err != nil {
+		t.Fatalf("TestExecutionResult Failed %v", err)
+	}
+
+	expected := []struct {
+		pk       string
+		sig      string
+		hashType uint32
+		err      string
+	}{
+		{"02aa", "30aa", 1, ""},
+		{"02cc", "30ff", 3, "Signature Mismatch"},
+		{"02bb", "30ff", 3, "Signature Mismatch"},
+		{"02aa", "", 0, "Empty Signature"},
+	}
+
+	if len(result.CheckSigs) != len(expected) {
+		t.Fatalf("TestExecutionResult expected %v attempts got %v", len(expected), len(result.CheckSigs))
+	}
+
+	for i, attempt := range result.CheckSigs {
+		if hex.EncodeToString(attempt.PublicKey) != expected[i].pk || hex.EncodeToString(attempt.Signature) != expected[i].sig || attempt.HashType != expected[i].hashType || (attempt.Err == nil) != (expected[i].err == "") || (attempt.Err != nil && attempt.Err.Error() != expected[i].err) {
+			t.Errorf("TestExecutionResult attempt %v expected %v got %x %x %v %v", i, expected[i], attempt.PublicKey, attempt.Signature, attempt.HashType, attempt.Err)
+		}
+	}
+}
+
+// errAny marks a test case that must fail without specifying why.
+var errAny = errors.New("Any Error")
+
+func TestMinimalData(t *testing.T) {
+	tests := []struct {
+		script string
+		err    error
+	}{
+		{"0101", ErrMinimalData},
+		{"4c0111", ErrMinimalData},
+		{"4c00", ErrMinimalData},
+		{"0111", nil},
+		{"00", nil},
+		//Only executed pushes are checked
+		{"0063010168", nil},
+		{"5163010168", ErrMinimalData},
+		//Numeric operands must be minimally encoded
+		{"01008b", scriptint.ErrNonMinimalNumber},
+		{"0201008b", scriptint.ErrNonMinimalNumber},
+		{"0180" + "8b", scriptint.ErrNonMinimalNumber},
+		{"02ff008b", nil},
+		{"0500000000008b", ErrNumberSize},
+		{"0100" + "63" + "68", nil},
+	}
+
+	for _, test := range tests {
+		script, _ := hex.DecodeString(test.script)
+
+		for _, engine := range []Engine{AstEngine, ScannerEngine} {
+			e := new(Executor)
+			e.Init(new(MockCheckSig))
+			e.Engine = engine
+			e.Flags = VerifyMinimalData
+
+			_, err := e.Execute(script)
+			if err != test.err {
+				t.Errorf("TestMinimalData %s engine %v expected %v got %v", test.script, engine, test.err, err)
+			}
+
+			//Without the flag every script is accepted
+			e.Flags = VerifyNone
+			e.stack = nil
+			if _, err = e.Execute(script); err != nil && err != ErrNumberSize {
+				t.Errorf("TestMinimalData %s engine %v without flag got %v", test.script, engine, err)
+			}
+		}
+	}
+}
+
+func TestSignatureEncoding(t *testing.T) {
+	der := func(r, s string) string {
+		rb, _ := hex.DecodeString(r)
+		sb, _ := hex.DecodeString(s)
+		return fmt.Sprintf("30%02x02%02x%s02%02x%s", 4+len(rb)+len(sb), len(rb), r, len(sb), s)
+	}
+
+	highS := "00" + hex.EncodeToString(new(big.Int).Sub(secp256k1.N, big.NewInt(1)).Bytes())
+	lowS := hex.EncodeToString(new(big.Int).Rsh(secp256k1.N, 1).Bytes())
+
+	compressed := "02" + strings.Repeat("11", 32)
+	uncompressed := "04" + strings.Repeat("11", 64)
+
+	const all = VerifyStrictEncoding | VerifyDERSignatures | VerifyLowS
+
+	tests := []struct {
+		sig   string
+		pk    string
+		flags VerifyFlags
+		err   error
+	}{
+		{der("01", "01") + "01", compressed, all, nil},
+		{der("01", "01") + "81", uncompressed, all, nil},
+		{der("01", lowS) + "01", compressed, all, nil},
+		{"", compressed, all, nil},
+
+		//Trailing garbage, wrong lengths, negative and padded integers
+		{der("01", "01") + "0001", compressed, VerifyDERSignatures, ErrSigDER},
+		{der("01", "01") + "0001", compressed, VerifyNone, nil},
+		{"3007020101020101" + "01", compressed, VerifyDERSignatures, ErrSigDER},
+		{"3106020101020101" + "01", compressed, VerifyDERSignatures, ErrSigDER},
+		{der("81", "01") + "01", compressed, VerifyDERSignatures, ErrSigDER},
+		{der("0001", "01") + "01", compressed, VerifyDERSignatures, ErrSigDER},
+		{der("0081", "01") + "01", compressed, VerifyDERSignatures, nil},
+		{der("01", "") + "01", compressed, VerifyDERSignatures, ErrSigDER},
+		{"0102", compressed, VerifyStrictEncoding, ErrSigDER},
+
+		{der("01", highS) + "01", compressed, VerifyLowS, ErrSigHighS},
+		{der("01", highS) + "01", compressed, VerifyDERSignatures, nil},
+
+		{der("01", "01") + "04", compressed, VerifyStrictEncoding, ErrSigHashType},
+		{der("01", "01") + "00", compressed, VerifyStrictEncoding, ErrSigHashType},
+		{der("01", "01") + "84", compressed, VerifyStrictEncoding, ErrSigHashType},
+		{der("01", "01") + "04", compressed, VerifyDERSignatures | VerifyLowS, nil},
+
+		{der("01", "01") + "01", "05" + strings.Repeat("11", 32), VerifyStrictEncoding, ErrPubKeyType},
+		{der("01", "01") + "01", "04" + strings.Repeat("11", 32), VerifyStrictEncoding, ErrPubKeyType},
+		{"", "", VerifyStrictEncoding, ErrPubKeyType},
+		{der("01", "01") + "01", "", VerifyDERSignatures | VerifyLowS, nil},
+	}
+
+	for _, test := range tests {
+		sig, _ := hex.DecodeString(test.sig)
+		pk, _ := hex.DecodeString(test.pk)
+
+		checkSig := compiler.AppendPushData(compiler.AppendPushData(nil, sig), pk)
+		checkSig = append(checkSig, byte(opcode.CHECKSIG))
+
+		//A single signature and key that are evaluated
+		checkMultiSig := append(compiler.AppendPushData([]byte{0x00}, sig), 0x51)
+		checkMultiSig = append(compiler.AppendPushData(checkMultiSig, pk), 0x51, byte(opcode.CHECKMULTISIG))
+
+		for _, script := range [][]byte{checkSig, checkMultiSig} {
+			e := new(Executor)
+			e.Init(new(MockCheckSig))
+			e.Flags = test.flags
+
+			_, err := e.Execute(script)
+			if err != test.err {
+				t.Errorf("TestSignatureEncoding %x expected %v got %v", script, test.err, err)
+			}
+		}
+	}
+
+	//Keys without a signature to check are never evaluated
+	e := new(Executor)
+	e.Init(new(MockCheckSig))
+	e.Flags = all
+	script, _ := hex.DecodeString("000001ff51ae")
+	if _, err := e.Execute(script); err != nil {
+		t.Errorf("TestSignatureEncoding unevaluated key failed %v", err)
+	}
+}
+
+func TestSubscriptify(t *testing.T) {
+	sig := bytes.Repeat([]byte{0x30}, 80)
+
+	tests := []struct {
+		script    string
+		subScript string
+	}{
+		//PUSHDATA length bytes are kept
+		{"4c02aabb76", "4c02aabb76"},
+		{"4d0200aabb76", "4d0200aabb76"},
+		{"4e02000000aabb76", "4e02000000aabb76"},
+		{"ab76ab87", "7687"},
+		//Only the standard push of the signature is removed
+		{"4c50" + hex.EncodeToString(sig) + "ac", "ac"},
+		{"4d5000" + hex.EncodeToString(sig) + "ac", "4d5000" + hex.EncodeToString(sig) + "ac"},
+	}
+
+	for _, test := range tests {
+		script, _ := hex.DecodeString(test.script)
+
+		subScript, err := Subscriptify(script, sig)
+		if err != nil {
+			t.Errorf("TestSubscriptify %.40s Failed %v", test.script, err)
+			continue
+		}
+
+		if hex.EncodeToString(subScript) != test.subScript {
+			t.Errorf("TestSubscriptify %.40s expected %.40s got %.40x", test.script, test.subScript, subScript)
+		}
+	}
+}
+
+func checkError(expected error, err error) bool {
+	if expected == errAny {
+		return err != nil
+	}
+
+	return err == expected
+}
+
+// pushArgs completes a push opcode with a single byte of data.
+func pushArgs(b byte) []byte {
+	switch {
+	case b == 0x00:
+		return nil
+	case b <= 0x4b:
+		return bytes.Repeat([]byte{0xaa}, int(b))
+	case b == 0x4c:
+		return []byte{0x01, 0xaa}
+	case b == 0x4d:
+		return []byte{0x01, 0x00, 0xaa}
+	case b == 0x4e:
+		return []byte{0x01, 0x00, 0x00, 0x00, 0xaa}
+	}
+
+	return nil
+}
+
+func TestOpCodeCoverage(t *testing.T) {
+
+	disabled := []byte{0x7e, 0x7f, 0x80, 0x81, 0x83, 0x84, 0x85, 0x86, 0x8d, 0x8e, 0x95, 0x96, 0x97, 0x98, 0x99}
+
+	//Every byte succeeds unless listed here
+	executed := map[byte]error{
+		0x50: ErrReservedOpCode,
+		0x62: ErrReservedOpCode,
+		0x63: errAny, //Unclosed IF
+		0x64: errAny, //Unclosed NOTIF
+		0x65: ErrVerIf,
+		0x66: ErrVerIf,
+		0x67: errAny, //Unexpected ELSE
+		0x68: errAny, //Unexpected ENDIF
+		0x6a: errAny, //RETURN
+		0x6c: ErrInvalidAltStackOperation,
+		0x89: ErrReservedOpCode,
+		0x8a: ErrReservedOpCode,
+	}
+	for _, b := range disabled {
+		executed[b] = ErrDisabledOpCode
+	}
+	for b := 0xba; b <= 0xff; b++ {
+		executed[byte(b)] = errAny
+	}
+
+	unexecuted := map[byte]error{
+		0x63: errAny, //Unclosed IF
+		0x64: errAny, //Unclosed NOTIF
+		0x65: ErrVerIf,
+		0x66: ErrVerIf,
+		0x68: errAny, //Unexpected ENDIF
+	}
+	for _, b := range disabled {
+		unexecuted[b] = ErrDisabledOpCode
+	}
+
+	for i := 0; i <= 0xff; i++ {
+		b := byte(i)
+		op := append([]byte{b}, pushArgs(b)...)
+
+		//Enough stack for any operation
+		script := append([]byte{0x51, 0x51, 0x51, 0x51, 0x51, 0x51}, op...)
+
+		e := new(Executor)
+		e.Init(new(MockCheckSig))
+		_, err := e.Execute(script)
+		if !checkError(executed[b], err) {
+			t.Errorf("TestOpCodeCoverage executed %02x expected %v got %v", b, executed[b], err)
+		}
+
+		script = append(append([]byte{0x00, 0x63}, op...), 0x68)
+
+		e = new(Executor)
+		e.Init(new(MockCheckSig))
+		_, err = e.Execute(script)
+		if !checkError(unexecuted[b], err) {
+			t.Errorf("TestOpCodeCoverage unexecuted %02x expected %v got %v", b, unexecuted[b], err)
+		}
+	}
+}
+
+func TestDisabledOpCodes(t *testing.T) {
+
+	tests := []string{
+		"CAT",
+		"0 IF CAT ENDIF",
+		"1 IF ELSE MUL ENDIF",
+		"1 NOTIF LSHIFT ELSE ENDIF",
+		"0 IF 1 IF 1 IF XOR ENDIF ENDIF ENDIF",
+		"1 IF 1 ELSE 0 IF 2MUL ENDIF ENDIF",
+		"1 RETURN 2DIV",
+	}
+
+	for _, test := range tests {
+		l := new(lexer.Lexer)
+		l.Init(strings.NewReader(test), nil)
+		compiled, err := compiler.Compile(l, nil)
+		if err != nil {
+			t.Errorf("TestDisabledOpCodes %s Compile Failed %v", test, err)
+			continue
+		}
+
+		e := new(Executor)
+		e.Init(new(MockCheckSig))
+		_, err = e.Execute(compiled)
+		if err != ErrDisabledOpCode {
+			t.Errorf("TestDisabledOpCodes %s ast expected %v got %v", test, ErrDisabledOpCode, err)
+		}
+
+		e = new(Executor)
+		e.Init(new(MockCheckSig))
+		err = e.execScanner(compiled)
+		if err != ErrDisabledOpCode {
+			t.Errorf("TestDisabledOpCodes %s scanner expected %v got %v", test, ErrDisabledOpCode, err)
+		}
+	}
+}
+
+// checkTop executes script with both engines and compares the top of the stack to result.
+func checkTop(t *testing.T, name string, script string, result string) {
+	l := new(lexer.Lexer)
+	l.Init(strings.NewReader(script), nil)
+	compiled, err := compiler.Compile(l, nil)
+	if err != nil {
+		t.Errorf("%s %s Compile Failed %v", name, script, err)
+		return
+	}
+
+	for _, engine := range []Engine{AstEngine, ScannerEngine} {
+		e := new(Executor)
+		e.Init(new(MockCheckSig))
+		e.Engine = engine
+		_, err = e.Execute(compiled)
+		if err != nil {
+			t.Errorf("%s %s engine %v Failed %v", name, script, engine, err)
+			continue
+		}
+
+		top, _ := e.Pop()
+		if hex.EncodeToString(top) != result {
+			t.Errorf("%s %s engine %v expected %s got %x", name, script, engine, result, top)
+		}
+	}
+}
+
+// WITHIN used to include max in the range.
+func TestWithinExcludesMax(t *testing.T) {
+	checkTop(t, "TestWithinExcludesMax", "2 1 2 WITHIN", "")
+	checkTop(t, "TestWithinExcludesMax", "1 1 2 WITHIN", "01")
+	checkTop(t, "TestWithinExcludesMax", "-1 -1 0 WITHIN", "01")
+	checkTop(t, "TestWithinExcludesMax", "0 -1 0 WITHIN", "")
+}
+
+// IFDUP used to duplicate false values instead of true ones.
+func TestIfDupDuplicatesTrue(t *testing.T) {
+	checkTop(t, "TestIfDupDuplicatesTrue", "1 IFDUP DEPTH", "02")
+	checkTop(t, "TestIfDupDuplicatesTrue", "0 IFDUP DEPTH", "01")
+	checkTop(t, "TestIfDupDuplicatesTrue", "0x80 IFDUP DEPTH", "01")
+}
+
+func TestSha256(t *testing.T) {
+	checkTop(t, "TestSha256", "0x616263 SHA256", "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad")
+}
+
+func TestSize(t *testing.T) {
+	checkTop(t, "TestSize", "0x616263 SIZE", "03")
+	checkTop(t, "TestSize", "0 SIZE", "")
+}
+
+// NOT and TOALTSTACK used to be rejected as invalid opcodes.
+func TestNotAndToAltStack(t *testing.T) {
+	checkTop(t, "TestNotAndToAltStack", "0 NOT", "01")
+	checkTop(t, "TestNotAndToAltStack", "2 NOT", "")
+	checkTop(t, "TestNotAndToAltStack", "1 TOALTSTACK 2 FROMALTSTACK", "01")
+}
+
+func TestMultipleElse(t *testing.T) {
+	checkTop(t, "TestMultipleElse", "1 IF 2 ELSE 3 ELSE 4 ENDIF ADD", "06")
+	checkTop(t, "TestMultipleElse", "0 IF 2 ELSE 3 ELSE 4 ENDIF", "03")
+	checkTop(t, "TestMultipleElse", "0 NOTIF 2 ELSE 3 ELSE 4 ENDIF ADD", "06")
+	checkTop(t, "TestMultipleElse", "1 IF 2 ELSE 3 ELSE 4 ELSE 5 ENDIF", "04")
+	checkTop(t, "TestMultipleElse", "0 IF 2 ELSE 3 ELSE 4 ELSE 5 ENDIF", "05")
+	checkTop(t, "TestMultipleElse", "1 0 IF ELSE IF 7 ELSE 8 ELSE 9 ENDIF ENDIF ADD", "10")
+}
+
+func TestLimits(t *testing.T) {
+
+	multisig := strings.Repeat("0 0 "+strings.Repeat("0 ", 20)+"20 CHECKMULTISIG DROP ", 9)
+
+	tests := []struct {
+		script string
+		err    error
+	}{
+		{strings.Repeat("NOP ", 201), nil},
+		{strings.Repeat("NOP ", 202), ErrOpCount},
+		{"0 IF " + strings.Repeat("NOP ", 199) + " ENDIF", nil},
+		{"0 IF " + strings.Repeat("NOP ", 200) + " ENDIF", ErrOpCount},
+		{multisig, nil},
+		{multisig + "0 0 " + strings.Repeat("0 ", 20) + "20 CHECKMULTISIG", ErrOpCount},
+		{strings.Repeat("1 ", 1000), nil},
+		{strings.Repeat("1 ", 1001), ErrStackSize},
+		{strings.Repeat("1 ", 999) + "TWODUP", ErrStackSize},
+		{"0x" + strings.Repeat("ff", 10001), ErrScriptSize},
+		{"0x" + strings.Repeat("ff", 520), nil},
+		{"0x" + strings.Repeat("ff", 521), ErrPushSize},
+		{"0 IF 0x" + strings.Repeat("ff", 521) + " ENDIF", ErrPushSize},
+		{"0xffffff7f 1ADD", nil},
+		{"0xffffff7f 1ADD 1ADD", ErrNumberSize},
+		{"0x0000000000 1 BOOLAND", ErrNumberSize},
+		{"0x0000000000 IF ENDIF", nil},
+		{"1 0x0100000000 PICK", ErrNumberSize},
+		//An oversized signature count is an error not a panic
+		{"0 0x0000000000 0 1 CHECKMULTISIG", ErrNumberSize},
+		{"0 0 21 CHECKMULTISIG", ErrPubKeyCount},
+		{"0 0 -1 CHECKMULTISIG", ErrPubKeyCount},
+		{"0 2 0x02aa 1 CHECKMULTISIG", ErrSigCount},
+		{"0 -1 0 CHECKMULTISIG", ErrSigCount},
+	}
+
+	for _, test := range tests {
+		l := new(lexer.Lexer)
+		l.Init(strings.NewReader(test.script), nil)
+		compiled, err := compiler.Compile(l, nil)
+		if err != nil {
+			t.Errorf("TestLimits %.40s Compile Failed %v", test.script, err)
+			continue
+		}
+
+		e := new(Executor)
+		e.Init(new(MockCheckSig))
+
+		_, err = e.Execute(compiled)
+		if err != test.err {
+			t.Errorf("TestLimits %.40s expected %v got %v", test.script, test.err, err)
+		}
+	}
+}
+
+func TestZeroValueLimits(t *testing.T) {
+	e := new(Executor)
+	e.signatureCheck = new(MockCheckSig)
+
+	_, err := e.Execute([]byte{0x51, 0x52, 0x93})
+	if err != nil {
+		t.Errorf("Zero value Executor failed %v", err)
+	}
+
+	if e.Limits != DefaultLimits {
+		t.Errorf("Expected DefaultLimits got %v", e.Limits)
+	}
+}
+
+func TestCustomLimits(t *testing.T) {
+
+	tests := []struct {
+		script string
+		limits Limits
+		err    error
+	}{
+		{"1 2 ADD", Limits{MaxScriptSize: 10, MaxPushSize: 10, MaxOps: 10, MaxStackSize: 1, MaxNumberSize: 4}, ErrStackSize},
+		{"0x" + strings.Repeat("00", 100), Limits{MaxScriptSize: 200, MaxPushSize: 100, MaxOps: 10, MaxStackSize: 10, MaxNumberSize: 4}, nil},
+		{"0x" + strings.Repeat("00", 101), Limits{MaxScriptSize: 200, MaxPushSize: 100, MaxOps: 10, MaxStackSize: 10, MaxNumberSize: 4}, ErrPushSize},
+		{"0 IF 0x" + strings.Repeat("00", 101) + " ENDIF", Limits{MaxScriptSize: 200, MaxPushSize: 100, MaxOps: 10, MaxStackSize: 10, MaxNumberSize: 4}, ErrPushSize},
+		{"1 1 ADD", Limits{MaxScriptSize: 3, MaxPushSize: 10, MaxOps: 10, MaxStackSize: 10, MaxNumberSize: 4}, nil},
+		{"1 1 ADD DROP", Limits{MaxScriptSize: 3, MaxPushSize: 10, MaxOps: 10, MaxStackSize: 10, MaxNumberSize: 4}, ErrScriptSize},
+		{"0x0100 1ADD", Limits{MaxScriptSize: 10, MaxPushSize: 10, MaxOps: 10, MaxStackSize: 10, MaxNumberSize: 1}, ErrNumberSize},
+	}
+
+	for _, test := range tests {
+		l := new(lexer.Lexer)
+		l.Init(strings.NewReader(test.script), nil)
+		compiled, _ := compiler.Compile(l, nil)
+
+		e := new(Executor)
+		e.Init(new(MockCheckSig))
+		e.Limits = test.limits
+
+		if _, err := e.Execute(compiled); err != test.err {
+			t.Errorf("TestCustomLimits %.40s expected %v got %v", test.script, test.err, err)
+		}
+	}
+}
+
+func TestVerifyScript(t *testing.T) {
+
+	//Transaction afd9c17f8913577ec3509520bd6e5d63e9c0fd2a5f70c787993b097ba6ca9fae all inputs spend the same address
+	raw, _ := hex.DecodeString("010000000370ac0a1ae588aaf284c308d67ca92c69a39e2db81337e563bf40c59da0a5cf63000000006a4730440220360d20baff382059040ba9be98947fd678fb08aab2bb0c172efa996fd8ece9b702201b4fb0de67f015c90e7ac8a193aeab486a1f587e0f54d0fb9552ef7f5ce6caec032103579ca2e6d107522f012cd00b52b9a65fb46f0c57b9b8b6e377c48f526a44741affffffff7d815b6447e35fbea097e00e028fb7dfbad4f3f0987b4734676c84f3fcd0e804010000006b483045022100c714310be1e3a9ff1c5f7cacc65c2d8e781fc3a88ceb063c6153bf950650802102200b2d0979c76e12bb480da635f192cc8dc6f905380dd4ac1ff35a4f68f462fffd032103579ca2e6d107522f012cd00b52b9a65fb46f0c57b9b8b6e377c48f526a44741affffffff3f1f097333e4d46d51f5e77b53264db8f7f5d2e18217e1099957d0f5af7713ee010000006c493046022100b663499ef73273a3788dea342717c2640ac43c5a1cf862c9e09b206fcb3f6bb8022100b09972e75972d9148f2bdd462e5cb69b57c1214b88fc55ca638676c07cfc10d8032103579ca2e6d107522f012cd00b52b9a65fb46f0c57b9b8b6e377c48f526a44741affffffff0380841e00000000001976a914bfb282c70c4191f45b5a6665cad1682f2c9cfdfb88ac80841e00000000001976a9149857cc07bed33a5cf12b9c5e0500b675d500c81188ace0fd1c00000000001976a91443c52850606c872403c0601e69fa34b26f62db4a88ac00000000")
+	scriptPubKey, _ := hex.DecodeString("76a914dcf72c4fd02f5a987cf9b02f2fabfcac3341a87d88ac")
+	wrongPubKey, _ := hex.DecodeString("76a914dcf72c4fd02f5a987cf9b02f2fabfcac3341a87e88ac")
+
+	var tx transaction.Tx
+	if err := tx.Set(raw); err != nil {
+		t.Fatalf("TestVerifyScript Tx.Set failed %v", err)
+	}
+
+	for i := range tx.Inputs {
+		err := VerifyScript(tx.Inputs[i].Script, scriptPubKey, &tx, i, VerifyNone)
+		if err != nil {
+			t.Errorf("TestVerifyScript input %v failed %v", i, err)
+		}
+	}
+
+	tests := []struct {
+		scriptSig    []byte
+		scriptPubKey []byte
+		inputIndex   int
+		err          error
+	}{
+		{tx.Inputs[0].Script, wrongPubKey, 0, ScriptError{Script: "scriptPubKey", Err: errors.New("OP_VERIFY False Transaction Invalid")}},
+		{tx.Inputs[0].Script, scriptPubKey, 1, ScriptError{Script: "scriptPubKey", Err: ErrEvalFalse}},
+		{tx.Inputs[0].Script, scriptPubKey, 3, transaction.InputIndexError(3)},
+		{nil, nil, 0, ScriptError{Script: "scriptPubKey", Err: ErrEvalFalse}},
+		{[]byte{0x51}, nil, 0, nil},
+		{[]byte{0x51}, []byte{0x00}, 0, ScriptError{Script: "scriptPubKey", Err: ErrEvalFalse}},
+		{[]byte{0x75}, []byte{0x51}, 0, ScriptError{Script: "scriptSig", Err: ErrStackUnderflow}},
+		{[]byte{0x51, 0x6b}, []byte{0x6c}, 0, ScriptError{Script: "scriptPubKey", Err: ErrInvalidAltStackOperation}},
+	}
+
+	for i, test := range tests {
+		err := VerifyScript(test.scriptSig, test.scriptPubKey, &tx, test.inputIndex, VerifyNone)
+		if fmt.Sprint(err) != fmt.Sprint(test.err) {
+			t.Errorf("TestVerifyScript %v expected %v got %v", i, test.err, err)
+		}
+	}
+
+	//The reason for the signature failure is available from the result
+	err := VerifyScript(tx.Inputs[0].Script, scriptPubKey, &tx, 1, VerifyNone)
+	scriptErr, ok := err.(ScriptError)
+	if !ok || len(scriptErr.Result.CheckSigs) != 1 || scriptErr.Result.CheckSigs[0].Err != secp256k1.ErrSignatureVerification {
+		t.Errorf("TestVerifyScript expected a failed signature check in the result got %v", err)
+	}
+	if err := VerifyScript([]byte{0x51}, []byte{0x51}, nil, 0, VerifyNone); err != ErrNoTransaction {
+		t.Errorf("TestVerifyScript without Tx expected %v got %v", ErrNoTransaction, err)
+	}
+}
+
+func TestVerifyScriptP2SH(t *testing.T) {
+
+	//2 of 3 multisig redeem script spent with the first and third keys
+	raw, _ := hex.DecodeString("0100000001420000000000000000000000000000000000000000000000000000000000000001000000fdfd0000483045022100a3f6059dc6f9e6f9998f9d2cb3f00ba525b1b580a2be7f9f49855fdc60a4195c022047f10714385f94433538ca1e9929aa7e35fac15355b6d9cf5599830ff51ae6b6014730440220057e1ea9c80a8328b5998943fc87df91cec5b64e91133f8f51bbc9e4c975f8e802200700cb3718d57ad31b2e27089a640a99cdbc275b0aff2ec4d189dc8c1743356e014c6952210279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f817982102c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee52102f9308a019258c31049344f85f89d5229b531c845836f99b08601f113bce036f953aeffffffff0150c300000000000017a91415fc0754e73eb85d1cbce08786fadb7320ecb8dc8700000000")
+	scriptPubKey, _ := hex.DecodeString("a91415fc0754e73eb85d1cbce08786fadb7320ecb8dc87")
+
+	var tx transaction.Tx
+	if err := tx.Set(raw); err != nil {
+		t.Fatalf("TestVerifyScriptP2SH Tx.Set failed %v", err)
+	}
+
+	scriptSig := tx.Inputs[0].Script
+	notPushOnly := append([]byte{0x61}, scriptSig...)
+	//Signatures in the wrong order
+	swapped, _ := hex.DecodeString("004730440220057e1ea9c80a8328b5998943fc87df91cec5b64e91133f8f51bbc9e4c975f8e802200700cb3718d57ad31b2e27089a640a99cdbc275b0aff2ec4d189dc8c1743356e01483045022100a3f6059dc6f9e6f9998f9d2cb3f00ba525b1b580a2be7f9f49855fdc60a4195c022047f10714385f94433538ca1e9929aa7e35fac15355b6d9cf5599830ff51ae6b6014c6952210279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f817982102c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee52102f9308a019258c31049344f85f89d5229b531c845836f99b08601f113bce036f953ae")
+	//Redeem script replaced by one with a different hash
+	wrongRedeem := append(append([]byte{}, scriptSig[:len(scriptSig)-107]...), 0x01, 0x51)
+
+	tests := []struct {
+		scriptSig []byte
+		flags     VerifyFlags
+		err       error
+	}{
+		{scriptSig, VerifyNone, nil},
+		{scriptSig, VerifyP2SH, nil},
+		{notPushOnly, VerifyNone, nil},
+		{notPushOnly, VerifyP2SH, ScriptError{Script: "scriptSig", Err: ErrSigPushOnly}},
+		{swapped, VerifyNone, nil},
+		{swapped, VerifyP2SH, ScriptError{Script: "redeemScript", Err: ErrEvalFalse}},
+		{wrongRedeem, VerifyP2SH, ScriptError{Script: "scriptPubKey", Err: ErrEvalFalse}},
+	}
+
+	for i, test := range tests {
+		err := VerifyScript(test.scriptSig, scriptPubKey, &tx, 0, test.flags)
+		if fmt.Sprint(err) != fmt.Sprint(test.err) {
+			t.Errorf("TestVerifyScriptP2SH %v expected %v got %v", i, test.err, err)
+		}
+
+		//Every failure carries the result of the failing script
+		if scriptErr, ok := err.(ScriptError); ok && scriptErr.Result == nil {
+			t.Errorf("TestVerifyScriptP2SH %v missing Result", i)
+		}
+	}
+}
+
+func TestIsPushOnly(t *testing.T) {
+	tests := []struct {
+		script   string
+		pushOnly bool
+	}{
+		{"", true},
+		{"00", true},
+		{"4f5060", true},
+		{"0201024c01ff", true},
+		{"61", false},
+		{"5161", false},
+		{"4c05ff", false},
+	}
+
+	for _, test := range tests {
+		script, _ := hex.DecodeString(test.script)
+		if IsPushOnly(script) != test.pushOnly {
+			t.Errorf("TestIsPushOnly %s expected %v", test.script, test.pushOnly)
+		}
+	}
+}
+
+func TestLockTime(t *testing.T) {
+
+	const cltv = VerifyCheckLockTimeVerify
+	const csv = VerifyCheckSequenceVerify
+
+	tests := []struct {
+		script   string
+		version  uint32
+		lockTime uint32
+		sequence uint32
+		flags    VerifyFlags
+		err      error
+	}{
+		{"100 CHECKLOCKTIMEVERIFY", 1, 100, 0, cltv, nil},
+		{"100 CHECKLOCKTIMEVERIFY", 1, 99, 0, cltv, ErrUnsatisfiedLockTime},
+		{"100 CHECKLOCKTIMEVERIFY", 1, 99, 0, VerifyNone, nil},
+		{"100 CHECKLOCKTIMEVERIFY", 1, 100, 0xffffffff, cltv, ErrUnsatisfiedLockTime},
+		{"100 CHECKLOCKTIMEVERIFY", 1, 500000000, 0, cltv, ErrUnsatisfiedLockTime},
+		{"500000000 CHECKLOCKTIMEVERIFY", 1, 600000000, 0, cltv, nil},
+		{"0xffffffff00 CHECKLOCKTIMEVERIFY", 1, 0xffffffff, 0, cltv, nil},
+		{"0xffffffff0000 CHECKLOCKTIMEVERIFY", 1, 0xffffffff, 0, cltv, ErrNumberSize},
+		{"-1 CHECKLOCKTIMEVERIFY", 1, 100, 0, cltv, ErrNegativeLockTime},
+		{"CHECKLOCKTIMEVERIFY", 1, 100, 0, cltv, ErrStackUnderflow},
+		{"10 CHECKSEQUENCEVERIFY", 2, 0, 10, csv, nil},
+		{"10 CHECKSEQUENCEVERIFY", 2, 0, 9, csv, ErrUnsatisfiedLockTime},
+		{"10 CHECKSEQUENCEVERIFY", 2, 0, 9, VerifyNone, nil},
+		{"10 CHECKSEQUENCEVERIFY", 1, 0, 10, csv, ErrUnsatisfiedLockTime},
+		{"10 CHECKSEQUENCEVERIFY", 2, 0, 0x8000000a, csv, ErrUnsatisfiedLockTime},
+		{"10 CHECKSEQUENCEVERIFY", 2, 0, 0x0040000a, csv, ErrUnsatisfiedLockTime},
+		{"0x0a0040 CHECKSEQUENCEVERIFY", 2, 0, 0x0040000a, csv, nil},
+		{"0x0a000100 CHECKSEQUENCEVERIFY", 2, 0, 0x0000000a, csv, nil},
+		{"0x0000008000 CHECKSEQUENCEVERIFY", 1, 0, 0xffffffff, csv, nil},
+		{"-1 CHECKSEQUENCEVERIFY", 2, 0, 10, csv, ErrNegativeLockTime},
+	}
+
+	for _, test := range tests {
+		l := new(lexer.Lexer)
+		l.Init(strings.NewReader(test.script), nil)
+		compiled, err := compiler.Compile(l, nil)
+		if err != nil {
+			t.Errorf("TestLockTime %s Compile Failed %v", test.script, err)
+			continue
+		}
+
+		tx := transaction.Tx{
+			Version:  test.version,
+			Inputs:   []transaction.TxIn{{Sequence: test.sequence}},
+			LockTime: test.lockTime,
+		}
+
+		e := new(Executor)
+		e.Init(new(MockCheckSig))
+		e.SetTx(&tx, 0)
+		e.Flags = test.flags
+
+		_, err = e.Execute(compiled)
+		if err != test.err {
+			t.Errorf("TestLockTime %s expected %v got %v", test.script, test.err, err)
+		}
+	}
+
+	e := new(Executor)
+	e.Init(new(MockCheckSig))
+	e.Flags = cltv
+	if _, err := e.Execute([]byte{0x51, 0xb1}); err != ErrNoTransaction {
+		t.Errorf("TestLockTime without Tx expected %v got %v", ErrNoTransaction, err)
+	}
+
+	//The empty stack is reported before the missing transaction
+	for _, script := range [][]byte{{0xb1}, {0xb2}} {
+		e := new(Executor)
+		e.Init(new(MockCheckSig))
+		e.Flags = cltv | VerifyCheckSequenceVerify
+		if _, err := e.Execute(script); err != ErrStackUnderflow {
+			t.Errorf("TestLockTime %x without Tx expected %v got %v", script, ErrStackUnderflow, err)
+		}
+	}
+}
+
+func TestTableTracer(t *testing.T) {
+	l := new(lexer.Lexer)
+	l.Init(strings.NewReader("1 2 ADD 3 EQUAL IF 0 0x0102 ENDIF DROP DROP DROP"), nil)
+	compiled, _ := compiler.Compile(l, nil)
+
+	var buffer bytes.Buffer
+	var tracer TableTracer
+	tracer.Init(&buffer)
+
+	e := new(Executor)
+	e.Init(new(MockCheckSig))
+	e.Tracer = &tracer
+
+	_, err := e.Execute(compiled)
+	if err != ErrStackUnderflow {
+		t.Errorf("TestTableTracer expected %v got %v", ErrStackUnderflow, err)
+	}
+	tracer.Flush()
+
+	expected := `STEP  POS  OPERATION  STACK      ALTSTACK  ERROR
+1     0    1          [01]       []        
+2     1    2          [01 02]    []        
+3     2    ADD        [03]       []        
+4     3    3          [03 03]    []        
+5     4    EQUAL      [01]       []        
+6     5    IF         [01]       []        
+7     6    0x         [<>]       []        
+8     7    0x0102     [<> 0102]  []        
+9     11   DROP       [<>]       []        
+10    12   DROP       []         []        
+11    13   DROP       []         []        Stack Underflow
+`
+	if buffer.String() != expected {
+		t.Errorf("TestTableTracer expected\n%s\ngot\n%s", expected, buffer.String())
+	}
+}
+
+func TestTracerRequiresAstEngine(t *testing.T) {
+	var tracer TableTracer
+	tracer.Init(ioutil.Discard)
+
+	e := new(Executor)
+	e.Init(new(MockCheckSig))
+	e.Engine = ScannerEngine
+	e.Tracer = &tracer
+
+	_, err := e.Execute([]byte{0x51})
+	if err != ErrTracerUnsupported {
+		t.Errorf("Expected ErrTracerUnsupported got %v", err)
+	}
+}
+
 func BenchmarkNops(b *testing.B) {
 	script := `
 NOP
@@ -156,7 +1119,7 @@ NOP
 
 	l := new(lexer.Lexer)
 	l.Init(strings.NewReader(script), nil)
-	compiled, _ := compiler.Compile(l)
+	compiled, _ := compiler.Compile(l, nil)
 
 	e := new(Executor)
 	e.Init(nil)
@@ -182,7 +1145,7 @@ CHECKSIG
 
 	l := new(lexer.Lexer)
 	l.Init(strings.NewReader(script), nil)
-	compiled, _ := compiler.Compile(l)
+	compiled, _ := compiler.Compile(l, nil)
 
 	e := new(Executor)
 	e.Init(nil)
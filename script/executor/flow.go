@@ -9,7 +9,10 @@ func op_NOP(c *Context) error {
 }
 
 func op_VERIFY(c *Context) error {
-	top := c.PopBool()
+	top, err := c.PopBool()
+	if err != nil {
+		return err
+	}
 	if !top {
 		return errors.New("OP_VERIFY False Transaction Invalid")
 	}
@@ -20,3 +23,12 @@ func op_VERIFY(c *Context) error {
 func op_RETURN(c *Context) error {
 	return errors.New("OP_RETURN Transaction Invalid")
 }
+
+func op_RESERVED(c *Context) error {
+	return ErrReservedOpCode
+}
+
+// VERIF and VERNOTIF are normally rejected before execution as they are invalid even in unexecuted branches.
+func op_VERIF(c *Context) error {
+	return ErrVerIf
+}
@@ -0,0 +1,75 @@
+package executor
+
+import (
+	"github.com/spearson78/guardian/script/limits"
+	"github.com/spearson78/guardian/script/scanner"
+	"github.com/spearson78/guardian/script/token"
+)
+
+// Limits are the resource limits enforced by the reference client when executing a script.
+type Limits struct {
+	// Maximum size of a script in bytes
+	MaxScriptSize int
+	// Maximum size of a single data push in bytes
+	MaxPushSize int
+	// Maximum number of non push operations including those in unexecuted branches
+	MaxOps int
+	// Maximum combined depth of the stack and alt stack
+	MaxStackSize int
+	// Maximum size in bytes of a numeric operand
+	MaxNumberSize int
+}
+
+var DefaultLimits = Limits{
+	MaxScriptSize: limits.MaxScriptSize,
+	MaxPushSize:   limits.MaxPushSize,
+	MaxOps:        limits.MaxOps,
+	MaxStackSize:  limits.MaxStackSize,
+	MaxNumberSize: limits.MaxNumberSize,
+}
+
+// CheckScriptLimits applies the limits that cover every operation in the script whether it is executed or not.
+// It returns the number of operations that count towards MaxOps.
+func CheckScriptLimits(script []byte, limits Limits) (int, error) {
+	if len(script) > limits.MaxScriptSize {
+		return 0, ErrScriptSize
+	}
+
+	s := new(scanner.Scanner)
+	s.Init(script, nil)
+
+	opCount := 0
+
+	for tok := s.Scan(); tok != token.ENDOFSCRIPT; tok = s.Scan() {
+		if tok == token.DATA && len(s.Data()) > limits.MaxPushSize {
+			return 0, ErrPushSize
+		}
+
+		//Everything above OP_16 counts as an operation
+		if s.ByteCode() > 0x60 {
+			opCount++
+			if opCount > limits.MaxOps {
+				return 0, ErrOpCount
+			}
+		}
+	}
+
+	return opCount, nil
+}
+
+func (this *Context) addOps(n int) error {
+	this.opCount += n
+	if this.opCount > this.Limits.MaxOps {
+		return ErrOpCount
+	}
+
+	return nil
+}
+
+func (this *Context) checkStackSize() error {
+	if len(this.stack)+len(this.altstack) > this.Limits.MaxStackSize {
+		return ErrStackSize
+	}
+
+	return nil
+}
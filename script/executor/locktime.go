@@ -0,0 +1,114 @@
+package executor
+
+import (
+	"github.com/spearson78/guardian/transaction"
+)
+
+const (
+	lockTimeThreshold = 500000000 //Lock times below this are block heights, above it they are timestamps
+	sequenceFinal     = 0xffffffff
+
+	sequenceLockTimeDisableFlag = 1 << 31
+	sequenceLockTimeTypeFlag    = 1 << 22
+	sequenceLockTimeMask        = 0x0000ffff
+
+	//Lock times are compared against uint32 fields so they are allowed one more byte than other numbers
+	maxLockTimeSize = 5
+)
+
+// peekLockTime returns the lock time on top of the stack.
+// Stack errors are reported before a missing transaction as in the reference client.
+func peekLockTime(c *Context) (int64, error) {
+	top, err := c.Peek()
+	if err != nil {
+		return 0, err
+	}
+
+	if c.tx == nil {
+		return 0, ErrNoTransaction
+	}
+
+	if c.inputIndex < 0 || c.inputIndex >= len(c.tx.Inputs) {
+		return 0, transaction.InputIndexError(c.inputIndex)
+	}
+
+	n, err := c.decodeNumber(top, maxLockTimeSize)
+	if err != nil {
+		return 0, err
+	}
+
+	if n.Sign() < 0 {
+		return 0, ErrNegativeLockTime
+	}
+
+	//5 bytes always fit into an int64
+	return n.Int64(), nil
+}
+
+func op_CHECKLOCKTIMEVERIFY(c *Context) error {
+	if c.Flags&VerifyCheckLockTimeVerify == 0 {
+		return op_NOP(c)
+	}
+
+	lockTime, err := peekLockTime(c)
+	if err != nil {
+		return err
+	}
+
+	txLockTime := int64(c.tx.LockTime)
+
+	//Heights and timestamps cannot be compared
+	if (txLockTime < lockTimeThreshold) != (lockTime < lockTimeThreshold) {
+		return ErrUnsatisfiedLockTime
+	}
+
+	if lockTime > txLockTime {
+		return ErrUnsatisfiedLockTime
+	}
+
+	//A final input disables the transaction lock time so it could be bypassed
+	if c.tx.Inputs[c.inputIndex].Sequence == sequenceFinal {
+		return ErrUnsatisfiedLockTime
+	}
+
+	return nil
+}
+
+func op_CHECKSEQUENCEVERIFY(c *Context) error {
+	if c.Flags&VerifyCheckSequenceVerify == 0 {
+		return op_NOP(c)
+	}
+
+	sequence, err := peekLockTime(c)
+	if err != nil {
+		return err
+	}
+
+	//Reserved for future soft forks, behaves as a NOP
+	if sequence&sequenceLockTimeDisableFlag != 0 {
+		return nil
+	}
+
+	if c.tx.Version < 2 {
+		return ErrUnsatisfiedLockTime
+	}
+
+	txSequence := int64(c.tx.Inputs[c.inputIndex].Sequence)
+	if txSequence&sequenceLockTimeDisableFlag != 0 {
+		return ErrUnsatisfiedLockTime
+	}
+
+	txSequence &= sequenceLockTimeTypeFlag | sequenceLockTimeMask
+	sequence &= sequenceLockTimeTypeFlag | sequenceLockTimeMask
+
+	//Block based and time based relative lock times cannot be compared
+	if (txSequence < sequenceLockTimeTypeFlag) != (sequence < sequenceLockTimeTypeFlag) {
+		return ErrUnsatisfiedLockTime
+	}
+
+	if sequence > txSequence {
+		return ErrUnsatisfiedLockTime
+	}
+
+	return nil
+}
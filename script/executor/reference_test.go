@@ -0,0 +1,167 @@
+package executor
+
+import (
+	"encoding/json"
+	"github.com/spearson78/guardian/script/compiler"
+	"github.com/spearson78/guardian/script/lexer"
+	"github.com/spearson78/guardian/transaction"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// Flags named in the test vectors that VerifyScript supports.
+var referenceFlags = map[string]VerifyFlags{
+	"":                    VerifyNone,
+	"NONE":                VerifyNone,
+	"P2SH":                VerifyP2SH,
+	"CHECKLOCKTIMEVERIFY": VerifyCheckLockTimeVerify,
+	"CHECKSEQUENCEVERIFY": VerifyCheckSequenceVerify,
+	"MINIMALDATA":         VerifyMinimalData,
+	"STRICTENC":           VerifyStrictEncoding,
+	"DERSIG":              VerifyDERSignatures,
+	"LOW_S":               VerifyLowS,
+}
+
+// referenceVector identifies a test vector by its contents so entries survive re-syncing script_tests.json.
+type referenceVector struct {
+	ScriptSig    string
+	ScriptPubKey string
+	Flags        string
+}
+
+// Vectors that fail because of known defects.
+// An entry that starts to pass is reported so the list stays accurate.
+var referenceKnownFailures = map[referenceVector]string{}
+
+// parseReferenceFlags returns the supported flags and whether every listed flag was supported.
+func parseReferenceFlags(s string) (VerifyFlags, bool) {
+	flags := VerifyNone
+	supported := true
+
+	for _, name := range strings.Split(s, ",") {
+		flag, found := referenceFlags[name]
+		if found {
+			flags |= flag
+		} else {
+			supported = false
+		}
+	}
+
+	return flags, supported
+}
+
+func compileReference(s string) ([]byte, error) {
+	var l lexer.ReferenceLexer
+	l.Init(strings.NewReader(s), nil)
+	return compiler.Compile(&l, nil)
+}
+
+// referenceSpend builds the transactions the reference client uses to evaluate a test vector.
+// A coinbase like transaction pays to scriptPubKey and is spent by scriptSig from its only input.
+func referenceSpend(scriptSig, scriptPubKey []byte) (*transaction.Tx, error) {
+	credit := transaction.Tx{
+		Version: 1,
+		Inputs: []transaction.TxIn{{
+			Previous: transaction.OutPoint{Index: 0xffffffff},
+			Script:   []byte{0x00, 0x00},
+			Sequence: 0xffffffff,
+		}},
+		Outputs: []transaction.TxOut{{
+			Value:  0,
+			Script: scriptPubKey,
+		}},
+	}
+
+	hash, err := credit.Hash()
+	if err != nil {
+		return nil, err
+	}
+
+	spend := &transaction.Tx{
+		Version: 1,
+		Inputs: []transaction.TxIn{{
+			Previous: transaction.OutPoint{Hash: hash, Index: 0},
+			Script:   scriptSig,
+			Sequence: 0xffffffff,
+		}},
+		Outputs: []transaction.TxOut{{
+			Value:  0,
+			Script: []byte{},
+		}},
+	}
+
+	return spend, nil
+}
+
+// Runs the reference client's script_tests.json, see testdata/README.
+// Cases expected to succeed are always run since ignoring a flag can only make validation less strict,
+// cases expected to fail are only run if every flag they list is supported. Witness cases are skipped.
+func TestReferenceScripts(t *testing.T) {
+	raw, err := ioutil.ReadFile("testdata/script_tests.json")
+	if err != nil {
+		t.Fatalf("TestReferenceScripts read failed %v", err)
+	}
+
+	var tests [][]interface{}
+	err = json.Unmarshal(raw, &tests)
+	if err != nil {
+		t.Fatalf("TestReferenceScripts unmarshal failed %v", err)
+	}
+
+	run, skipped := 0, 0
+	for i, test := range tests {
+		//Comments
+		if len(test) < 4 {
+			continue
+		}
+
+		//Witness cases start with an array of witness data
+		if _, ok := test[0].(string); !ok {
+			skipped++
+			continue
+		}
+
+		sigText, pubKeyText, flagText, expected := test[0].(string), test[1].(string), test[2].(string), test[3].(string)
+
+		flags, supported := parseReferenceFlags(flagText)
+		if expected != "OK" && !supported {
+			skipped++
+			continue
+		}
+
+		scriptSig, err := compileReference(sigText)
+		if err != nil {
+			t.Errorf("TestReferenceScripts %v scriptSig %q compile failed %v", i, sigText, err)
+			continue
+		}
+
+		scriptPubKey, err := compileReference(pubKeyText)
+		if err != nil {
+			t.Errorf("TestReferenceScripts %v scriptPubKey %q compile failed %v", i, pubKeyText, err)
+			continue
+		}
+
+		spend, err := referenceSpend(scriptSig, scriptPubKey)
+		if err != nil {
+			t.Fatalf("TestReferenceScripts %v spend failed %v", i, err)
+		}
+
+		run++
+		err = VerifyScript(scriptSig, scriptPubKey, spend, 0, flags)
+		passed := (err == nil) == (expected == "OK")
+
+		if reason, known := referenceKnownFailures[referenceVector{sigText, pubKeyText, flagText}]; known {
+			if passed {
+				t.Errorf("TestReferenceScripts %v is listed as a known failure (%s) but passed", i, reason)
+			}
+			continue
+		}
+
+		if !passed {
+			t.Errorf("TestReferenceScripts %v [%q %q %q] expected %v got %v", i, sigText, pubKeyText, flagText, expected, err)
+		}
+	}
+
+	t.Logf("TestReferenceScripts ran %v skipped %v", run, skipped)
+}
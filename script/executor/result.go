@@ -0,0 +1,15 @@
+package executor
+
+// CheckSigAttempt records one signature check made by CHECKSIG or CHECKMULTISIG.
+// Signature excludes the trailing hash type byte, Err is nil if the signature was valid.
+type CheckSigAttempt struct {
+	PublicKey []byte
+	Signature []byte
+	HashType  uint32
+	Err       error
+}
+
+// ExecutionResult collects the details of a script execution that do not cause it to fail.
+type ExecutionResult struct {
+	CheckSigs []CheckSigAttempt
+}
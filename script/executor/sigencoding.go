@@ -0,0 +1,111 @@
+package executor
+
+import (
+	"github.com/spearson78/guardian/crypto/secp256k1"
+	"math/big"
+)
+
+const (
+	sigHashAll          = 0x01
+	sigHashSingle       = 0x03
+	sigHashAnyoneCanPay = 0x80
+)
+
+var halfOrder = new(big.Int).Rsh(secp256k1.N, 1)
+
+// checkEncoding applies the VerifyStrictEncoding, VerifyDERSignatures and VerifyLowS rules to a signature,
+// which includes its hash type byte, and the public key it is checked against.
+// An empty signature is always allowed as it is the standard way to provide a failing signature.
+func (this *Context) checkEncoding(pk []byte, sig []byte) error {
+	if len(sig) != 0 {
+		if this.Flags&(VerifyStrictEncoding|VerifyDERSignatures|VerifyLowS) != 0 && !isValidSignatureEncoding(sig) {
+			return ErrSigDER
+		}
+
+		if this.Flags&VerifyLowS != 0 && !isLowS(sig[:len(sig)-1]) {
+			return ErrSigHighS
+		}
+
+		if this.Flags&VerifyStrictEncoding != 0 && !isDefinedHashType(sig[len(sig)-1]) {
+			return ErrSigHashType
+		}
+	}
+
+	if this.Flags&VerifyStrictEncoding != 0 && !isValidPublicKeyEncoding(pk) {
+		return ErrPubKeyType
+	}
+
+	return nil
+}
+
+// isValidSignatureEncoding checks the strict DER encoding of BIP66 followed by the hash type byte:
+// 0x30 [total-length] 0x02 [R-length] [R] 0x02 [S-length] [S] [sighash]
+// with minimally encoded positive integers and no trailing bytes.
+func isValidSignatureEncoding(sig []byte) bool {
+	if len(sig) < 9 || len(sig) > 73 {
+		return false
+	}
+
+	if sig[0] != 0x30 || int(sig[1]) != len(sig)-3 {
+		return false
+	}
+
+	lenR := int(sig[3])
+	if 5+lenR >= len(sig) {
+		return false
+	}
+
+	lenS := int(sig[5+lenR])
+	if lenR+lenS+7 != len(sig) {
+		return false
+	}
+
+	return isValidInteger(sig[2:4+lenR]) && isValidInteger(sig[4+lenR:6+lenR+lenS])
+}
+
+// isValidInteger checks a DER integer element including its type and length bytes.
+func isValidInteger(element []byte) bool {
+	if element[0] != 0x02 || element[1] == 0 {
+		return false
+	}
+
+	value := element[2:]
+
+	//Negative numbers are not allowed
+	if value[0]&0x80 != 0 {
+		return false
+	}
+
+	//A leading zero byte is only allowed to keep the number positive
+	if len(value) > 1 && value[0] == 0x00 && value[1]&0x80 == 0 {
+		return false
+	}
+
+	return true
+}
+
+// isLowS reports whether the S value of a strictly encoded signature is at most half the curve order.
+func isLowS(der []byte) bool {
+	sig, err := secp256k1.ParseSignature(der)
+	if err != nil {
+		return false
+	}
+
+	return sig.S.Cmp(halfOrder) <= 0
+}
+
+func isDefinedHashType(hashType byte) bool {
+	base := hashType &^ sigHashAnyoneCanPay
+	return base >= sigHashAll && base <= sigHashSingle
+}
+
+func isValidPublicKeyEncoding(pk []byte) bool {
+	switch {
+	case len(pk) == 65:
+		return pk[0] == 0x04
+	case len(pk) == 33:
+		return pk[0] == 0x02 || pk[0] == 0x03
+	}
+
+	return false
+}
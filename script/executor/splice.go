@@ -0,0 +1,15 @@
+package executor
+
+import (
+	"math/big"
+)
+
+func op_SIZE(c *Context) error {
+	top, err := c.Peek()
+	if err != nil {
+		return err
+	}
+
+	c.PushNumber(big.NewInt(int64(len(top))))
+	return nil
+}
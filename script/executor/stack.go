@@ -1,27 +1,37 @@
 package executor
 
 import (
-	"errors"
 	"github.com/spearson78/guardian/encoding/scriptint"
 	"math/big"
 )
 
 func op_TOALTSTACK(c *Context) error {
-	c.AltPush(c.Pop())
+	top, err := c.Pop()
+	if err != nil {
+		return err
+	}
+
+	c.AltPush(top)
 	return nil
 }
 
 func op_FROMALTSTACK(c *Context) error {
-	c.Push(c.AltPop())
+	top, err := c.AltPop()
+	if err != nil {
+		return err
+	}
+
+	c.Push(top)
 	return nil
 }
 
 func op_IFDUP(c *Context) error {
-	top := c.Peek()
-	if top == nil {
-		return errors.New("Stack Underflow")
+	top, err := c.Peek()
+	if err != nil {
+		return err
 	}
-	if scriptint.Decode(top).Sign() == 0 {
+	//Only true values are duplicated, negative zero is false
+	if scriptint.Decode(top).Sign() != 0 {
 		c.Push(top)
 	}
 	return nil
@@ -33,14 +43,14 @@ func op_DEPTH(c *Context) error {
 }
 
 func op_DROP(c *Context) error {
-	c.Pop()
-	return nil
+	_, err := c.Pop()
+	return err
 }
 
 func op_DUP(c *Context) error {
-	top := c.Peek()
-	if top == nil {
-		return errors.New("Stack Underflow")
+	top, err := c.Peek()
+	if err != nil {
+		return err
 	}
 	c.Push(top)
 
@@ -48,26 +58,45 @@ func op_DUP(c *Context) error {
 }
 
 func op_NIP(c *Context) error {
-	x2 := c.Pop()
-	c.Pop()
-
-	c.Push(x2)
-	return nil
-
+	_, err := c.PopN(1)
+	return err
 }
 
 func op_OVER(c *Context) error {
-	x1 := c.PeekN(1)
+	x1, err := c.PeekN(1)
+	if err != nil {
+		return err
+	}
 	c.Push(x1)
 
 	return nil
 }
 
+// popIndex pops the stack index used by PICK and ROLL, it must refer to an item below it on the stack.
+func popIndex(c *Context) (int, error) {
+	n, err := c.PopNumber()
+	if err != nil {
+		return 0, err
+	}
+
+	if n.Sign() < 0 || n.Cmp(big.NewInt(int64(c.Depth()))) >= 0 {
+		return 0, ErrInvalidStackOperation
+	}
+
+	return int(n.Int64()), nil
+}
+
 func op_PICK(c *Context) error {
 
-	n := int(c.PopNumber().Int64())
+	n, err := popIndex(c)
+	if err != nil {
+		return err
+	}
 
-	xn := c.PeekN(n)
+	xn, err := c.PeekN(n)
+	if err != nil {
+		return err
+	}
 	c.Push(xn)
 
 	return nil
@@ -75,9 +104,15 @@ func op_PICK(c *Context) error {
 
 func op_ROLL(c *Context) error {
 
-	n := int(c.PopNumber().Int64())
+	n, err := popIndex(c)
+	if err != nil {
+		return err
+	}
 
-	xn := c.PopN(n)
+	xn, err := c.PopN(n)
+	if err != nil {
+		return err
+	}
 	c.Push(xn)
 
 	return nil
@@ -85,12 +120,11 @@ func op_ROLL(c *Context) error {
 
 func op_ROT(c *Context) error {
 
-	x3 := c.Pop()
-	x2 := c.Pop()
-	x1 := c.Pop()
+	x1, err := c.PopN(2)
+	if err != nil {
+		return err
+	}
 
-	c.Push(x2)
-	c.Push(x3)
 	c.Push(x1)
 
 	return nil
@@ -98,10 +132,11 @@ func op_ROT(c *Context) error {
 
 func op_SWAP(c *Context) error {
 
-	x2 := c.Pop()
-	x1 := c.Pop()
+	x1, err := c.PopN(1)
+	if err != nil {
+		return err
+	}
 
-	c.Push(x2)
 	c.Push(x1)
 
 	return nil
@@ -109,8 +144,12 @@ func op_SWAP(c *Context) error {
 
 func op_TUCK(c *Context) error {
 
-	x2 := c.Pop()
-	x1 := c.Pop()
+	if c.Depth() < 2 {
+		return ErrStackUnderflow
+	}
+
+	x2, _ := c.Pop()
+	x1, _ := c.Pop()
 
 	c.Push(x2)
 	c.Push(x1)
@@ -121,40 +160,46 @@ func op_TUCK(c *Context) error {
 
 func op_TWODROP(c *Context) error {
 
+	if c.Depth() < 2 {
+		return ErrStackUnderflow
+	}
+
 	c.Pop()
 	c.Pop()
 
 	return nil
 }
 
-func op_TWODUP(c *Context) error {
-
-	x1 := c.PeekN(1)
-	x2 := c.PeekN(0)
+// dupN duplicates the top n items in the same order
+func dupN(c *Context, n int) error {
+	if c.Depth() < n {
+		return ErrStackUnderflow
+	}
 
-	c.Push(x1)
-	c.Push(x2)
+	for i := 0; i < n; i++ {
+		x, _ := c.PeekN(n - 1)
+		c.Push(x)
+	}
 
 	return nil
 }
 
-func op_THREEDUP(c *Context) error {
-
-	x1 := c.PeekN(2)
-	x2 := c.PeekN(1)
-	x3 := c.PeekN(0)
-
-	c.Push(x1)
-	c.Push(x2)
-	c.Push(x3)
+func op_TWODUP(c *Context) error {
+	return dupN(c, 2)
+}
 
-	return nil
+func op_THREEDUP(c *Context) error {
+	return dupN(c, 3)
 }
 
 func op_TWOOVER(c *Context) error {
 
-	x1 := c.PeekN(3)
-	x2 := c.PeekN(2)
+	if c.Depth() < 4 {
+		return ErrStackUnderflow
+	}
+
+	x1, _ := c.PeekN(3)
+	x2, _ := c.PeekN(2)
 
 	c.Push(x1)
 	c.Push(x2)
@@ -164,8 +209,12 @@ func op_TWOOVER(c *Context) error {
 
 func op_TWOROT(c *Context) error {
 
-	x2 := c.PopN(4)
-	x1 := c.PopN(4)
+	if c.Depth() < 6 {
+		return ErrStackUnderflow
+	}
+
+	x1, _ := c.PopN(5)
+	x2, _ := c.PopN(4)
 
 	c.Push(x1)
 	c.Push(x2)
@@ -175,8 +224,12 @@ func op_TWOROT(c *Context) error {
 
 func op_TWOSWAP(c *Context) error {
 
-	x2 := c.PopN(2)
-	x1 := c.PopN(2)
+	if c.Depth() < 4 {
+		return ErrStackUnderflow
+	}
+
+	x1, _ := c.PopN(3)
+	x2, _ := c.PopN(2)
 
 	c.Push(x1)
 	c.Push(x2)
@@ -0,0 +1,151 @@
+package executor
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/spearson78/guardian/script/ast"
+	"github.com/spearson78/guardian/script/opcode"
+	"github.com/spearson78/guardian/script/scanner"
+	"github.com/spearson78/guardian/script/token"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// Tracer is called by the Executor before and after each ast.Node is visited.
+// IF and CODESEPARATOR nodes contain other nodes so their After call follows those of their contents.
+type Tracer interface {
+	Before(step *Step)
+	After(step *Step, err error)
+}
+
+// Step is the state of the Executor around the execution of a single node.
+// Pos is the offset of the node's first byte in the script and OpCode is the byte found there.
+// The stacks are copies with the top of the stack last, the items themselves are shared and must not be modified.
+type Step struct {
+	Node     ast.Node
+	Pos      int
+	OpCode   opcode.OpCode
+	Stack    [][]byte
+	AltStack [][]byte
+}
+
+func (this *Executor) newStep(node ast.Node) *Step {
+	step := &Step{
+		Node:   node,
+		Pos:    -1,
+		OpCode: opcode.INVALID,
+	}
+
+	//An empty block has no position
+	if b, ok := node.(*ast.SimpleBlock); !ok || len(b.NodeList) != 0 {
+		//Nodes record the position following their bytecode
+		if start, found := this.startPos[node.Pos()]; found {
+			step.Pos = start
+			step.OpCode = opcode.OpCode(this.script[start])
+		}
+	}
+
+	this.updateStep(step)
+	return step
+}
+
+// indexStartPos maps the end position of every token in the script to its start position.
+func (this *Executor) indexStartPos(script []byte) {
+	this.startPos = make(map[int]int)
+
+	s := new(scanner.Scanner)
+	s.Init(script, nil)
+
+	for {
+		start := s.Pos()
+		if s.Scan() == token.ENDOFSCRIPT {
+			break
+		}
+		this.startPos[s.Pos()] = start
+	}
+}
+
+func (this *Executor) updateStep(step *Step) {
+	step.Stack = append([][]byte(nil), this.stack...)
+	step.AltStack = append([][]byte(nil), this.altstack...)
+}
+
+// TableTracer writes one row per executed step showing the operation and the resulting stacks.
+type TableTracer struct {
+	w     *tabwriter.Writer
+	count int
+}
+
+func (this *TableTracer) Init(w io.Writer) {
+	this.w = tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	this.count = 0
+	fmt.Fprintln(this.w, "STEP\tPOS\tOPERATION\tSTACK\tALTSTACK\tERROR")
+}
+
+func (this *TableTracer) Before(step *Step) {
+	//Containers are reported before their contents so the table stays in script order
+	if isContainer(step.Node) {
+		this.row(step, nil)
+	}
+}
+
+func (this *TableTracer) After(step *Step, err error) {
+	if !isContainer(step.Node) || err != nil {
+		this.row(step, err)
+	}
+}
+
+// Flush must be called once execution has finished to write the table.
+func (this *TableTracer) Flush() error {
+	return this.w.Flush()
+}
+
+func (this *TableTracer) row(step *Step, err error) {
+	this.count++
+
+	errText := ""
+	if err != nil {
+		errText = err.Error()
+	}
+
+	fmt.Fprintf(this.w, "%v\t%v\t%v\t%v\t%v\t%v\n", this.count, step.Pos, nodeName(step.Node), formatStack(step.Stack), formatStack(step.AltStack), errText)
+}
+
+func isContainer(node ast.Node) bool {
+	switch node.(type) {
+	case *ast.IfStmt, ast.Block:
+		return true
+	}
+
+	return false
+}
+
+func nodeName(node ast.Node) string {
+	switch n := node.(type) {
+	case *ast.IfStmt:
+		if n.Not {
+			return "NOTIF"
+		}
+		return "IF"
+	case *ast.CodeSeparator:
+		return "CODESEPARATOR"
+	case *ast.SimpleBlock:
+		return "BLOCK"
+	}
+
+	return node.String()
+}
+
+func formatStack(stack [][]byte) string {
+	items := make([]string, len(stack))
+	for i, item := range stack {
+		if len(item) == 0 {
+			items[i] = "<>"
+		} else {
+			items[i] = hex.EncodeToString(item)
+		}
+	}
+
+	return "[" + strings.Join(items, " ") + "]"
+}
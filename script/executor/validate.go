@@ -0,0 +1,95 @@
+package executor
+
+import (
+	"github.com/spearson78/guardian/script/ast"
+	"github.com/spearson78/guardian/script/opcode"
+	"github.com/spearson78/guardian/script/scanner"
+	"github.com/spearson78/guardian/script/token"
+)
+
+// checkOpCode rejects operations that invalidate a script wherever they appear, including unexecuted branches.
+func checkOpCode(op opcode.OpCode) error {
+	if op == opcode.VERIF || op == opcode.VERNOTIF {
+		return ErrVerIf
+	}
+
+	if op.IsDisabled() {
+		return ErrDisabledOpCode
+	}
+
+	return nil
+}
+
+// validate applies checkOpCode to every operation in the tree before the AST engine executes it.
+func validate(block ast.Block) error {
+	for _, node := range block.List() {
+		switch n := node.(type) {
+		case *ast.Operation:
+			err := checkOpCode(n.OpCode)
+			if err != nil {
+				return err
+			}
+		case *ast.IfStmt:
+			err := validate(n.Body)
+			if err != nil {
+				return err
+			}
+
+			for _, elseBlock := range n.Else {
+				err = validate(elseBlock)
+				if err != nil {
+					return err
+				}
+			}
+		case ast.Block:
+			err := validate(n)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateScript checks the bytecode before the scanner engine executes it.
+// It reports the same errors in the same order as ast.Parse followed by validate so both engines agree.
+func validateScript(script []byte) error {
+	s := new(scanner.Scanner)
+	s.Init(script, nil)
+
+	ifDepth := 0
+	var opErr error
+
+	for tok := s.Scan(); tok != token.ENDOFSCRIPT; tok = s.Scan() {
+		switch tok {
+		case token.INVALID:
+			return ast.ErrInvalidToken
+		case token.IF, token.NOTIF:
+			ifDepth++
+		case token.ELSE:
+			if ifDepth == 0 {
+				return ast.ErrUnexpectedElse
+			}
+		case token.ENDIF:
+			if ifDepth == 0 {
+				return ast.ErrUnexpectedEndIf
+			}
+			ifDepth--
+		case token.OPERATION:
+			if opErr == nil {
+				opErr = checkOpCode(s.Op())
+			}
+		}
+	}
+
+	if ifDepth != 0 {
+		return ast.ErrUnclosedIf
+	}
+
+	if s.ErrorCount() != 0 {
+		return ast.ErrTokenSourceError
+	}
+
+	return opErr
+}
@@ -0,0 +1,155 @@
+package executor
+
+import (
+	"github.com/spearson78/guardian/crypto/secp256k1"
+	"github.com/spearson78/guardian/script/scanner"
+	"github.com/spearson78/guardian/script/token"
+	"github.com/spearson78/guardian/transaction"
+)
+
+// VerifyFlags select the optional script validation rules applied by VerifyScript.
+type VerifyFlags uint32
+
+const VerifyNone VerifyFlags = 0
+
+const (
+	// Evaluate the redeem script of pay to script hash outputs (BIP16)
+	VerifyP2SH VerifyFlags = 1 << iota
+	// Treat NOP2 as CHECKLOCKTIMEVERIFY (BIP65)
+	VerifyCheckLockTimeVerify
+	// Treat NOP3 as CHECKSEQUENCEVERIFY (BIP112)
+	VerifyCheckSequenceVerify
+	// Require minimal pushes and minimally encoded numeric operands (BIP62)
+	VerifyMinimalData
+	// Require strict DER signatures with a defined hash type and compressed or uncompressed public keys
+	VerifyStrictEncoding
+	// Require strict DER signatures (BIP66)
+	VerifyDERSignatures
+	// Require strict DER signatures with S at most half the curve order (BIP62)
+	VerifyLowS
+)
+
+// ScriptError reports which script of a spend failed and why.
+// Result holds the signature checks made by the failing script.
+type ScriptError struct {
+	Script string
+	Err    error
+	Result *ExecutionResult
+}
+
+func (e ScriptError) Error() string {
+	return e.Script + " - " + e.Err.Error()
+}
+
+// VerifyScript checks that scriptSig satisfies scriptPubKey for the given input of tx.
+// The scriptSig is executed first and the scriptPubKey is then executed against a copy of the resulting stack,
+// the spend is valid if the top of the final stack is true.
+// With VerifyP2SH a pay to script hash scriptPubKey additionally requires a push only scriptSig whose last push,
+// the redeem script, also succeeds when executed against the rest of the scriptSig stack.
+func VerifyScript(scriptSig, scriptPubKey []byte, tx *transaction.Tx, inputIndex int, flags VerifyFlags) error {
+	if tx == nil {
+		return ErrNoTransaction
+	}
+
+	if inputIndex < 0 || inputIndex >= len(tx.Inputs) {
+		return transaction.InputIndexError(inputIndex)
+	}
+
+	checker := &secp256k1.SignatureCheck{
+		Tx:         tx,
+		InputIndex: inputIndex,
+	}
+
+	var e Executor
+	e.Init(checker)
+	e.SetTx(tx, inputIndex)
+	e.Flags = flags
+
+	sigResult, err := e.Execute(scriptSig)
+	if err != nil {
+		return ScriptError{Script: "scriptSig", Err: err, Result: sigResult}
+	}
+
+	var pubKeyExecutor Executor
+	pubKeyExecutor.Init(checker)
+	pubKeyExecutor.SetTx(tx, inputIndex)
+	pubKeyExecutor.Flags = flags
+	pubKeyExecutor.stack = e.copyStack()
+
+	result, err := pubKeyExecutor.Execute(scriptPubKey)
+	if err != nil {
+		return ScriptError{Script: "scriptPubKey", Err: err, Result: result}
+	}
+
+	err = pubKeyExecutor.checkResult()
+	if err != nil {
+		return ScriptError{Script: "scriptPubKey", Err: err, Result: result}
+	}
+
+	if flags&VerifyP2SH != 0 && IsPayToScriptHash(scriptPubKey) {
+		if !IsPushOnly(scriptSig) {
+			return ScriptError{Script: "scriptSig", Err: ErrSigPushOnly, Result: sigResult}
+		}
+
+		//The scriptSig stack is untouched by the scriptPubKey execution and the hash check guarantees it is not empty
+		redeemScript, err := e.Pop()
+		if err != nil {
+			return ScriptError{Script: "scriptSig", Err: err, Result: sigResult}
+		}
+
+		result, err = e.Execute(redeemScript)
+		if err != nil {
+			return ScriptError{Script: "redeemScript", Err: err, Result: result}
+		}
+
+		err = e.checkResult()
+		if err != nil {
+			return ScriptError{Script: "redeemScript", Err: err, Result: result}
+		}
+	}
+
+	return nil
+}
+
+// IsPayToScriptHash reports whether script has the exact BIP16 form HASH160 <20 bytes> EQUAL.
+func IsPayToScriptHash(script []byte) bool {
+	return len(script) == 23 && script[0] == 0xa9 && script[1] == 0x14 && script[22] == 0x87
+}
+
+// IsPushOnly reports whether script contains nothing but data and number pushes.
+func IsPushOnly(script []byte) bool {
+	s := new(scanner.Scanner)
+	s.Init(script, nil)
+
+	for tok := s.Scan(); tok != token.ENDOFSCRIPT; tok = s.Scan() {
+		//RESERVED is considered a push by the reference client
+		if tok == token.INVALID || s.ByteCode() > 0x60 {
+			return false
+		}
+	}
+
+	return s.ErrorCount() == 0
+}
+
+func (this *Context) copyStack() [][]byte {
+	stack := make([][]byte, len(this.stack))
+	copy(stack, this.stack)
+	return stack
+}
+
+func (this *Context) checkResult() error {
+	if len(this.stack) == 0 {
+		return ErrEvalFalse
+	}
+
+	top, err := this.PopBool()
+	if err != nil {
+		return err
+	}
+
+	if !top {
+		return ErrEvalFalse
+	}
+
+	return nil
+}
@@ -2,41 +2,132 @@ package lexer
 
 import (
 	"encoding/hex"
+	"github.com/spearson78/guardian/encoding/address"
 	"github.com/spearson78/guardian/script/opcode"
 	"github.com/spearson78/guardian/script/token"
 	"io"
+	"math"
 	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"text/scanner"
 )
 
-type ErrorHandler func(byteCodePos int, msg string)
+// MaxIncludeDepth limits nested INCLUDEs, which also stops an include cycle.
+const MaxIncludeDepth = 16
 
+// ErrorHandler is called for every error found in the source.
+type ErrorHandler func(pos scanner.Position, msg string)
+
+// Error is a single error found in the source, it prints as file:line:col: msg.
+type Error struct {
+	Pos scanner.Position
+	Msg string
+}
+
+func (e Error) Error() string {
+	return e.Pos.String() + ": " + e.Msg
+}
+
+// Lexer reads script source text.
+// Lexing continues after an error so a single pass reports every problem in the source.
+//
+// The source may contain:
+//
+//	DEFINE name value   name is replaced by the single token value from here on
+//	<name>              a PLACEHOLDER token bound at compile time
+//	INCLUDE "file"      the tokens of file are read in place of the INCLUDE
+//	"text" 'c' `raw`    DATA holding the unquoted bytes, Go escapes are allowed
+//	ADDRESS "1..."      DATA holding the hash of a base58check address
+//	[1 2 255]           DATA holding the decimal bytes
+//	@"file"             DATA holding the binary contents of file
 type Lexer struct {
-	s          scanner.Scanner
-	errorCount int
-	err        ErrorHandler
+	s        *scanner.Scanner
+	includes []include
+	defines  map[string]define
+	pos      scanner.Position
+	errors   []Error
+	err      ErrorHandler
+
+	op     opcode.OpCode
+	data   []byte
+	number *big.Int
+
+	// Open opens INCLUDEd and @ files.
+	// When nil files are opened from the file system relative to the including file.
+	Open func(name string) (io.ReadCloser, error)
+}
 
+// include is a suspended source waiting for an included file to finish.
+type include struct {
+	parent *scanner.Scanner
+	file   io.Closer
+}
+
+type define struct {
+	tok    token.Token
 	op     opcode.OpCode
 	data   []byte
 	number *big.Int
 }
 
 func (l *Lexer) Init(r io.Reader, err ErrorHandler) {
-	l.s.Init(r)
-	l.s.Mode = scanner.ScanIdents | scanner.ScanFloats | scanner.ScanChars | scanner.ScanStrings | scanner.ScanRawStrings | scanner.ScanComments | scanner.SkipComments
+	l.InitFile(r, "", err)
+}
+
+// InitFile is Init for source read from filename, which is included in positions.
+func (l *Lexer) InitFile(r io.Reader, filename string, err ErrorHandler) {
+	for _, suspended := range l.includes {
+		suspended.file.Close()
+	}
+
+	l.s = l.newScanner(r, filename)
+	l.includes = nil
+	l.defines = make(map[string]define)
+	l.pos = scanner.Position{}
+	l.errors = nil
 	l.err = err
 }
 
+func (l *Lexer) newScanner(r io.Reader, filename string) *scanner.Scanner {
+	s := new(scanner.Scanner)
+	s.Init(r)
+	s.Mode = scanner.ScanIdents | scanner.ScanFloats | scanner.ScanChars | scanner.ScanStrings | scanner.ScanRawStrings | scanner.ScanComments | scanner.SkipComments
+	s.Filename = filename
+	s.Error = func(s *scanner.Scanner, msg string) {
+		l.raiseErrorAt(s.Pos(), msg)
+	}
+	return s
+}
+
 func (l *Lexer) raiseError(msg string) {
-	l.errorCount++
+	l.raiseErrorAt(l.pos, msg)
+}
+
+func (l *Lexer) raiseErrorAt(pos scanner.Position, msg string) {
+	l.errors = append(l.errors, Error{Pos: pos, Msg: msg})
 	if l.err != nil {
-		l.err(0, msg)
+		l.err(pos, msg)
 	}
 }
 
+// Pos returns the byte offset of the start of the last token, the Offset of Position.
+// The offset is only meaningful within one file, it restarts at 0 inside an INCLUDEd file
+// so tokens from different files may share offsets. Use Position to tell the files apart.
 func (l *Lexer) Pos() int {
-	return l.s.Pos().Line
+	return l.pos.Offset
+}
+
+// Position returns the file, line and column of the start of the last token.
+func (l *Lexer) Position() scanner.Position {
+	return l.pos
+}
+
+// Errors returns every error found so far in source order.
+func (l *Lexer) Errors() []Error {
+	return l.errors
 }
 
 func (l *Lexer) Op() opcode.OpCode {
@@ -52,7 +143,7 @@ func (l *Lexer) Number() *big.Int {
 }
 
 func (l *Lexer) ErrorCount() int {
-	return l.errorCount
+	return len(l.errors)
 }
 
 func (l *Lexer) Scan() (tok token.Token) {
@@ -64,7 +155,9 @@ func (l *Lexer) Scan() (tok token.Token) {
 	l.data = nil
 	l.number = nil
 
+	errorCount := len(l.errors)
 	stok := l.s.Scan()
+	l.pos = l.s.Position
 	switch stok {
 	case scanner.Ident:
 		switch l.s.TokenText() {
@@ -78,7 +171,41 @@ func (l *Lexer) Scan() (tok token.Token) {
 			tok = token.ENDIF
 		case "CODESEPARATOR":
 			tok = token.CODESEPARATOR
+		case "PUSHDATA1", "PUSHDATA2", "PUSHDATA4":
+			//Explicit push widths preserve non minimal pushes
+			tok = token.BYTECODE
+			op := l.s.TokenText()
+			data, ok := l.scanHex(op)
+			if ok {
+				l.data, ok = appendExplicitPushData(nil, op, data)
+				if !ok {
+					l.raiseError("Data Too Long For " + op)
+				}
+			}
+		case "BYTECODE":
+			tok = token.BYTECODE
+			l.data, _ = l.scanHex("BYTECODE")
+		case "ADDRESS":
+			tok = token.DATA
+			l.data = l.scanAddress()
+		case "DEFINE":
+			l.define()
+			return l.Scan()
+		case "INCLUDE":
+			l.include()
+			return l.Scan()
 		default:
+			if d, ok := l.defines[l.s.TokenText()]; ok {
+				tok = d.tok
+				l.op = d.op
+				l.data = d.data
+				if d.number != nil {
+					//Copied as a following - negates the number in place
+					l.number = new(big.Int).Set(d.number)
+				}
+				break
+			}
+
 			tok = token.OPERATION
 			l.op = opcode.Parse(l.s.TokenText())
 			if l.op == opcode.INVALID {
@@ -99,25 +226,266 @@ func (l *Lexer) Scan() (tok token.Token) {
 		}
 	case scanner.Float:
 		l.raiseError("Floating Point Not Supported " + l.s.TokenText())
-	case scanner.Char:
+	case scanner.Char, scanner.String, scanner.RawString:
+		text, err := strconv.Unquote(l.s.TokenText())
+		if err != nil {
+			//Malformed literals have usually been reported by the scanner already
+			if len(l.errors) == errorCount {
+				l.raiseError("Invalid Literal " + l.s.TokenText())
+			}
+			break
+		}
 		tok = token.DATA
-		l.data = []byte(l.s.TokenText())
-	case scanner.String:
+		l.data = []byte(text)
+	case '[':
 		tok = token.DATA
-		l.data = []byte(l.s.TokenText())
-	case scanner.RawString:
+		l.data = l.scanBytes()
+	case '@':
 		tok = token.DATA
-		l.data = []byte(l.s.TokenText())
+		l.data = l.scanFile()
 	case scanner.EOF:
+		if len(l.includes) != 0 {
+			//Resume the including source
+			suspended := l.includes[len(l.includes)-1]
+			l.includes = l.includes[:len(l.includes)-1]
+			suspended.file.Close()
+			l.s = suspended.parent
+			return l.Scan()
+		}
 		tok = token.ENDOFSCRIPT
+	case '<':
+		if l.s.Scan() != scanner.Ident {
+			l.raiseErrorAt(l.s.Position, "Expected Placeholder Name")
+			break
+		}
+		name := l.s.TokenText()
+		if l.s.Scan() != '>' {
+			l.raiseErrorAt(l.s.Position, "Expected > After Placeholder "+name)
+			break
+		}
+		tok = token.PLACEHOLDER
+		l.data = []byte(name)
 	case '-':
+		//Whitespace may separate the - from its number, nothing else is consumed on error
+		for ch := l.s.Peek(); ch >= 0 && ch < 64 && l.s.Whitespace&(1<<uint(ch)) != 0; ch = l.s.Peek() {
+			l.s.Next()
+		}
+
+		if !isDigit(l.s.Peek()) {
+			l.raiseError("Expected Number After -")
+			break
+		}
+
+		pos := l.pos
 		tok = l.Scan()
+		l.pos = pos
 		if tok == token.NUMBER {
 			l.number.Neg(l.number)
 		} else {
+			l.raiseError("Expected Number After -")
 			tok = token.INVALID
 		}
+	default:
+		l.raiseError("Unexpected " + l.s.TokenText())
 	}
 
 	return
 }
+
+// define reads the name and value following DEFINE.
+func (l *Lexer) define() {
+	if l.s.Scan() != scanner.Ident {
+		l.raiseErrorAt(l.s.Position, "Expected Name After DEFINE")
+		return
+	}
+
+	name := l.s.TokenText()
+	namePos := l.s.Position
+
+	tok := l.Scan()
+	if tok == token.INVALID || tok == token.ENDOFSCRIPT {
+		l.raiseErrorAt(namePos, "Expected Value For DEFINE "+name)
+		return
+	}
+
+	if isReserved(name) {
+		l.raiseErrorAt(namePos, "Cannot DEFINE Reserved Word "+name)
+		return
+	}
+
+	if _, ok := l.defines[name]; ok {
+		l.raiseErrorAt(namePos, "Duplicate DEFINE "+name)
+		return
+	}
+
+	l.defines[name] = define{tok: tok, op: l.op, data: l.data, number: l.number}
+}
+
+func isReserved(name string) bool {
+	switch name {
+	case "IF", "NOTIF", "ELSE", "ENDIF", "CODESEPARATOR", "PUSHDATA1", "PUSHDATA2", "PUSHDATA4", "BYTECODE", "DEFINE", "INCLUDE", "ADDRESS":
+		return true
+	}
+
+	return opcode.Parse(name) != opcode.INVALID
+}
+
+// scanString reads the string literal that must follow the keyword.
+func (l *Lexer) scanString(keyword string) (string, bool) {
+	if l.s.Scan() != scanner.String {
+		l.raiseErrorAt(l.s.Position, "Expected String After "+keyword)
+		return "", false
+	}
+
+	text, err := strconv.Unquote(l.s.TokenText())
+	if err != nil {
+		l.raiseErrorAt(l.s.Position, "Invalid Literal "+l.s.TokenText())
+		return "", false
+	}
+
+	return text, true
+}
+
+// open opens the named file with Open or relative to the current file, returning the name used in positions.
+func (l *Lexer) open(name string) (io.ReadCloser, string, error) {
+	if l.Open != nil {
+		file, err := l.Open(name)
+		return file, name, err
+	}
+
+	if !filepath.IsAbs(name) {
+		name = filepath.Join(filepath.Dir(l.s.Filename), name)
+	}
+	file, err := os.Open(name)
+	return file, name, err
+}
+
+// include switches to the file named after INCLUDE, Scan resumes the current source at its end.
+func (l *Lexer) include() {
+	name, ok := l.scanString("INCLUDE")
+	if !ok {
+		return
+	}
+	pos := l.s.Position
+
+	if len(l.includes) >= MaxIncludeDepth {
+		l.raiseErrorAt(pos, "Include Depth Exceeded "+name)
+		return
+	}
+
+	file, name, err := l.open(name)
+	if err != nil {
+		l.raiseErrorAt(pos, "Include Failed "+err.Error())
+		return
+	}
+
+	l.includes = append(l.includes, include{parent: l.s, file: file})
+	l.s = l.newScanner(file, name)
+}
+
+// scanFile reads the contents of the file named after @.
+func (l *Lexer) scanFile() []byte {
+	name, ok := l.scanString("@")
+	if !ok {
+		return nil
+	}
+	pos := l.s.Position
+
+	file, _, err := l.open(name)
+	if err != nil {
+		l.raiseErrorAt(pos, "Read Failed "+err.Error())
+		return nil
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		l.raiseErrorAt(pos, "Read Failed "+err.Error())
+		return nil
+	}
+
+	return data
+}
+
+// scanAddress reads the base58check address following ADDRESS and returns its hash.
+// The version byte is not checked so addresses from any network are accepted.
+func (l *Lexer) scanAddress() []byte {
+	text, ok := l.scanString("ADDRESS")
+	if !ok {
+		return nil
+	}
+
+	hash, _, err := address.Decode(text)
+	if err != nil {
+		l.raiseErrorAt(l.s.Position, "Invalid Address "+err.Error())
+		return nil
+	}
+
+	return hash
+}
+
+// scanBytes reads decimal bytes up to the closing ].
+func (l *Lexer) scanBytes() []byte {
+	data := []byte{}
+	for {
+		switch l.s.Scan() {
+		case ']':
+			return data
+		case scanner.Int:
+			//Base 10 only so 0x10 is not silently accepted
+			b, err := strconv.ParseUint(l.s.TokenText(), 10, 8)
+			if err != nil {
+				l.raiseErrorAt(l.s.Position, "Invalid Byte "+l.s.TokenText())
+			}
+			data = append(data, byte(b))
+		case scanner.EOF:
+			l.raiseErrorAt(l.s.Position, "Expected ]")
+			return data
+		default:
+			l.raiseErrorAt(l.s.Position, "Invalid Byte "+l.s.TokenText())
+		}
+	}
+}
+
+func isDigit(ch rune) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+// scanHex reads the hex literal that must follow the keyword.
+func (l *Lexer) scanHex(keyword string) ([]byte, bool) {
+	if l.s.Scan() != scanner.Int || !strings.HasPrefix(l.s.TokenText(), "0x") {
+		l.raiseErrorAt(l.s.Position, "Expected Hex After "+keyword)
+		return nil, false
+	}
+
+	data, err := hex.DecodeString(l.s.TokenText()[2:])
+	if err != nil {
+		l.raiseErrorAt(l.s.Position, "Hex Decode Failed "+l.s.TokenText()+err.Error())
+		return nil, false
+	}
+
+	return data, true
+}
+
+func appendExplicitPushData(dst []byte, op string, data []byte) ([]byte, bool) {
+	l := len(data)
+	switch op {
+	case "PUSHDATA1":
+		if l > math.MaxUint8 {
+			return nil, false
+		}
+		dst = append(dst, 0x4c, byte(l))
+	case "PUSHDATA2":
+		if l > math.MaxUint16 {
+			return nil, false
+		}
+		dst = append(dst, 0x4d, byte(l&0xFF), byte((l>>8)&0xFF))
+	default:
+		if uint64(l) > math.MaxUint32 {
+			return nil, false
+		}
+		dst = append(dst, 0x4e, byte(l&0xFF), byte((l>>8)&0xFF), byte((l>>16)&0xFF), byte((l>>24)&0xFF))
+	}
+
+	return append(dst, data...), true
+}
@@ -3,11 +3,16 @@ package lexer
 import (
 	"bytes"
 	"encoding/hex"
+	"errors"
 	"github.com/spearson78/guardian/script/opcode"
 	"github.com/spearson78/guardian/script/token"
+	"io"
 	"math/big"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"text/scanner"
 )
 
 func TestComments(t *testing.T) {
@@ -30,7 +35,7 @@ CHECKSIG
 
 	errorReported := false
 
-	l.Init(strings.NewReader(script), func(pos int, msg string) {
+	l.Init(strings.NewReader(script), func(pos scanner.Position, msg string) {
 		errorReported = true
 	})
 
@@ -93,7 +98,7 @@ CHECKSIG
 
 	errorReported := false
 
-	l.Init(strings.NewReader(script), func(pos int, msg string) {
+	l.Init(strings.NewReader(script), func(pos scanner.Position, msg string) {
 		errorReported = true
 	})
 
@@ -148,7 +153,7 @@ func TestMinusOne(t *testing.T) {
 
 	errorReported := false
 
-	l.Init(strings.NewReader(script), func(pos int, msg string) {
+	l.Init(strings.NewReader(script), func(pos scanner.Position, msg string) {
 		errorReported = true
 	})
 
@@ -171,6 +176,45 @@ func TestMinusOne(t *testing.T) {
 	}
 }
 
+func TestMinusWhitespace(t *testing.T) {
+	tests := []struct {
+		script string
+		number int64
+	}{
+		{"- 5", -5},
+		{"-\t\n 5", -5},
+		{"-5", -5},
+	}
+
+	for _, test := range tests {
+		var l Lexer
+		l.Init(strings.NewReader(test.script), nil)
+
+		tok := l.Scan()
+		if tok != token.NUMBER || l.Number().Int64() != test.number {
+			t.Errorf("%q expected %d got %s %v", test.script, test.number, tok, l.Number())
+		}
+
+		if l.Position().Column != 1 {
+			t.Errorf("%q expected the position of the - got %s", test.script, l.Position())
+		}
+
+		if tok = l.Scan(); tok != token.ENDOFSCRIPT || l.ErrorCount() != 0 {
+			t.Errorf("%q expected ENDOFSCRIPT got %s %v", test.script, tok, l.Errors())
+		}
+	}
+
+	//Only whitespace is skipped
+	var l Lexer
+	l.Init(strings.NewReader("- DUP"), nil)
+	if tok := l.Scan(); tok != token.INVALID || l.ErrorCount() != 1 {
+		t.Errorf("- DUP expected INVALID got %s %v", tok, l.Errors())
+	}
+	if tok := l.Scan(); tok != token.OPERATION || l.Op() != opcode.DUP {
+		t.Errorf("- DUP expected DUP got %s %s", tok, l.Op())
+	}
+}
+
 func TestMinusOperation(t *testing.T) {
 	script := "-DUP"
 
@@ -178,7 +222,7 @@ func TestMinusOperation(t *testing.T) {
 
 	errorReported := false
 
-	l.Init(strings.NewReader(script), func(pos int, msg string) {
+	l.Init(strings.NewReader(script), func(pos scanner.Position, msg string) {
 		errorReported = true
 	})
 
@@ -187,16 +231,22 @@ func TestMinusOperation(t *testing.T) {
 		t.Errorf("Failed -DUP tok %s op %s", tok, l.Op())
 	}
 
+	//The operation is not consumed by the failed minus
+	tok = l.Scan()
+	if tok != token.OPERATION || l.Op() != opcode.DUP {
+		t.Errorf("Failed DUP tok %s op %s", tok, l.Op())
+	}
+
 	tok = l.Scan()
 	if tok != token.ENDOFSCRIPT {
 		t.Errorf("Failed double ENDOFSCRIPT tok %s", tok)
 	}
 
-	if errorReported {
-		t.Errorf("Failed ErrorReported")
+	if !errorReported {
+		t.Errorf("Failed No ErrorReported")
 	}
 
-	if l.ErrorCount() != 0 {
+	if l.ErrorCount() != 1 {
 		t.Errorf("Failed ErrorCount() %d", l.ErrorCount())
 	}
 }
@@ -208,7 +258,7 @@ func TestMinus(t *testing.T) {
 
 	errorReported := false
 
-	l.Init(strings.NewReader(script), func(pos int, msg string) {
+	l.Init(strings.NewReader(script), func(pos scanner.Position, msg string) {
 		errorReported = true
 	})
 
@@ -222,11 +272,11 @@ func TestMinus(t *testing.T) {
 		t.Errorf("Failed double ENDOFSCRIPT tok %s", tok)
 	}
 
-	if errorReported {
-		t.Errorf("Failed ErrorReported")
+	if !errorReported {
+		t.Errorf("Failed No ErrorReported")
 	}
 
-	if l.ErrorCount() != 0 {
+	if l.ErrorCount() != 1 {
 		t.Errorf("Failed ErrorCount() %d", l.ErrorCount())
 	}
 }
@@ -246,7 +296,7 @@ CHECKSIG
 
 	errorReported := false
 
-	l.Init(strings.NewReader(script), func(pos int, msg string) {
+	l.Init(strings.NewReader(script), func(pos scanner.Position, msg string) {
 		errorReported = true
 	})
 
@@ -294,6 +344,455 @@ CHECKSIG
 	}
 }
 
+func TestPositions(t *testing.T) {
+	script := "DUP\n  HASH160 -5\n\t0x01"
+
+	var l Lexer
+	l.InitFile(strings.NewReader(script), "p2pkh.script", nil)
+
+	expected := []string{
+		"p2pkh.script:1:1",
+		"p2pkh.script:2:3",
+		"p2pkh.script:2:11",
+		"p2pkh.script:3:2",
+	}
+
+	for _, position := range expected {
+		l.Scan()
+		if l.Position().String() != position {
+			t.Errorf("Expected %s got %s", position, l.Position())
+		}
+		if l.Pos() != l.Position().Offset {
+			t.Errorf("%s Pos %d does not match Offset %d", position, l.Pos(), l.Position().Offset)
+		}
+	}
+
+	if l.Pos() != strings.Index(script, "0x01") {
+		t.Errorf("Expected offset %d got %d", strings.Index(script, "0x01"), l.Pos())
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	script := `DUP BAD 1.5
+HASH160 - 0xzz #
+PUSHDATA1 DUP "unterminated`
+
+	var reported []string
+
+	var l Lexer
+	l.InitFile(strings.NewReader(script), "bad.script", func(pos scanner.Position, msg string) {
+		reported = append(reported, pos.String())
+	})
+
+	var ops []opcode.OpCode
+	for tok := l.Scan(); tok != token.ENDOFSCRIPT; tok = l.Scan() {
+		if tok == token.OPERATION {
+			ops = append(ops, l.Op())
+		}
+	}
+
+	expected := []string{
+		"bad.script:1:5",
+		"bad.script:1:9",
+		"bad.script:2:13",
+		"bad.script:2:9",
+		"bad.script:2:13",
+		"bad.script:2:16",
+		"bad.script:3:11",
+		"bad.script:3:28",
+	}
+
+	if strings.Join(reported, " ") != strings.Join(expected, " ") {
+		t.Errorf("Expected errors at\n%v got\n%v", expected, reported)
+	}
+
+	errors := l.Errors()
+	if len(errors) != len(expected) || l.ErrorCount() != len(expected) {
+		t.Fatalf("Expected %d errors got %d %d", len(expected), len(errors), l.ErrorCount())
+	}
+
+	if errors[0].Error() != "bad.script:1:5: Invalid Operation BAD" {
+		t.Errorf("Unexpected error text %s", errors[0])
+	}
+
+	//Every operation is still returned, the scanner stops the hex literal at zz
+	if len(ops) != 4 || ops[0] != opcode.DUP || ops[1] != opcode.INVALID || ops[2] != opcode.HASH160 || ops[3] != opcode.INVALID {
+		t.Errorf("Unexpected operations %v", ops)
+	}
+}
+
+type tokenValue struct {
+	tok    token.Token
+	op     opcode.OpCode
+	data   string
+	number int64
+}
+
+func scanAll(l *Lexer) []tokenValue {
+	var values []tokenValue
+	for tok := l.Scan(); tok != token.ENDOFSCRIPT; tok = l.Scan() {
+		value := tokenValue{tok: tok, op: l.Op(), data: hex.EncodeToString(l.Data())}
+		if l.Number() != nil {
+			value.number = l.Number().Int64()
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+func checkTokens(t *testing.T, name string, values []tokenValue, expected []tokenValue) {
+	if len(values) != len(expected) {
+		t.Errorf("%s expected %v got %v", name, expected, values)
+		return
+	}
+
+	for i := range values {
+		if values[i] != expected[i] {
+			t.Errorf("%s expected %v got %v", name, expected, values)
+			return
+		}
+	}
+}
+
+func TestDefine(t *testing.T) {
+	script := `DEFINE TIMEOUT 1000
+DEFINE KEY 0x0102
+DEFINE CHECK CHECKSIGVERIFY
+DEFINE LATER TIMEOUT
+KEY CHECK TIMEOUT -5 LATER`
+
+	var l Lexer
+	l.Init(strings.NewReader(script), nil)
+
+	checkTokens(t, "Define", scanAll(&l), []tokenValue{
+		{token.DATA, opcode.INVALID, "0102", 0},
+		{token.OPERATION, opcode.CHECKSIGVERIFY, "", 0},
+		{token.NUMBER, opcode.INVALID, "", 1000},
+		{token.NUMBER, opcode.INVALID, "", -5},
+		{token.NUMBER, opcode.INVALID, "", 1000},
+	})
+
+	if l.ErrorCount() != 0 {
+		t.Errorf("Unexpected errors %v", l.Errors())
+	}
+}
+
+func TestDefineErrors(t *testing.T) {
+	tests := []string{
+		"DEFINE",
+		"DEFINE 1 2",
+		"DEFINE X",
+		"DEFINE DUP 1",
+		"DEFINE IF 1",
+		"DEFINE X 1 DEFINE X 2",
+	}
+
+	for _, script := range tests {
+		var l Lexer
+		l.Init(strings.NewReader(script), nil)
+		scanAll(&l)
+
+		if l.ErrorCount() != 1 {
+			t.Errorf("%s expected 1 error got %v", script, l.Errors())
+		}
+	}
+}
+
+func TestPlaceholder(t *testing.T) {
+	script := "DUP HASH160 <pubKeyHash> EQUALVERIFY CHECKSIG DEFINE HASH <hash> HASH"
+
+	var l Lexer
+	l.Init(strings.NewReader(script), nil)
+
+	checkTokens(t, "Placeholder", scanAll(&l), []tokenValue{
+		{token.OPERATION, opcode.DUP, "", 0},
+		{token.OPERATION, opcode.HASH160, "", 0},
+		{token.PLACEHOLDER, opcode.INVALID, hex.EncodeToString([]byte("pubKeyHash")), 0},
+		{token.OPERATION, opcode.EQUALVERIFY, "", 0},
+		{token.OPERATION, opcode.CHECKSIG, "", 0},
+		{token.PLACEHOLDER, opcode.INVALID, hex.EncodeToString([]byte("hash")), 0},
+	})
+
+	for _, script := range []string{"<>", "<1>", "<key", "<key DUP>"} {
+		l.Init(strings.NewReader(script), nil)
+		scanAll(&l)
+		if l.ErrorCount() == 0 {
+			t.Errorf("%s expected errors", script)
+		}
+	}
+}
+
+type memoryFiles map[string]string
+
+func (this memoryFiles) Open(name string) (io.ReadCloser, error) {
+	source, ok := this[name]
+	if !ok {
+		return nil, errors.New("Not Found " + name)
+	}
+	return io.NopCloser(strings.NewReader(source)), nil
+}
+
+func TestInclude(t *testing.T) {
+	files := memoryFiles{
+		"p2pkh.script":  "DUP HASH160 <hash> EQUALVERIFY CHECKSIG",
+		"consts.script": "DEFINE TIMEOUT 1000\nINCLUDE \"nested.script\"",
+		"nested.script": "DEFINE FLAG 0x01",
+		"loop.script":   "INCLUDE \"loop.script\"",
+		"bad.script":    "DUP\n  BAD",
+	}
+
+	script := `INCLUDE "consts.script" TIMEOUT CHECKLOCKTIMEVERIFY DROP FLAG INCLUDE "p2pkh.script"`
+
+	var l Lexer
+	l.Open = files.Open
+	l.Init(strings.NewReader(script), nil)
+
+	checkTokens(t, "Include", scanAll(&l), []tokenValue{
+		{token.NUMBER, opcode.INVALID, "", 1000},
+		{token.OPERATION, opcode.CHECKLOCKTIMEVERIFY, "", 0},
+		{token.OPERATION, opcode.DROP, "", 0},
+		{token.DATA, opcode.INVALID, "01", 0},
+		{token.OPERATION, opcode.DUP, "", 0},
+		{token.OPERATION, opcode.HASH160, "", 0},
+		{token.PLACEHOLDER, opcode.INVALID, hex.EncodeToString([]byte("hash")), 0},
+		{token.OPERATION, opcode.EQUALVERIFY, "", 0},
+		{token.OPERATION, opcode.CHECKSIG, "", 0},
+	})
+
+	if l.ErrorCount() != 0 {
+		t.Errorf("Unexpected errors %v", l.Errors())
+	}
+
+	l.Init(strings.NewReader(`INCLUDE "bad.script" DUP`), nil)
+	scanAll(&l)
+	if len(l.Errors()) != 1 || l.Errors()[0].Pos.String() != "bad.script:2:3" {
+		t.Errorf("Expected error in bad.script got %v", l.Errors())
+	}
+
+	l.Init(strings.NewReader(`INCLUDE "loop.script"`), nil)
+	scanAll(&l)
+	if len(l.Errors()) != 1 || !strings.HasPrefix(l.Errors()[0].Msg, "Include Depth Exceeded") {
+		t.Errorf("Expected include depth error got %v", l.Errors())
+	}
+
+	for _, script := range []string{`INCLUDE "missing.script"`, "INCLUDE DUP", "INCLUDE"} {
+		l.Init(strings.NewReader(script), nil)
+		scanAll(&l)
+		if l.ErrorCount() != 1 {
+			t.Errorf("%s expected 1 error got %v", script, l.Errors())
+		}
+	}
+}
+
+func TestIncludeRelativeToFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "lib"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	os.WriteFile(filepath.Join(dir, "main.script"), []byte(`INCLUDE "lib/a.script" DROP`), 0644)
+	os.WriteFile(filepath.Join(dir, "lib", "a.script"), []byte(`INCLUDE "b.script" DUP`), 0644)
+	os.WriteFile(filepath.Join(dir, "lib", "b.script"), []byte(`1`), 0644)
+
+	name := filepath.Join(dir, "main.script")
+	file, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var l Lexer
+	l.InitFile(file, name, nil)
+
+	checkTokens(t, "IncludeRelativeToFile", scanAll(&l), []tokenValue{
+		{token.NUMBER, opcode.INVALID, "", 1},
+		{token.OPERATION, opcode.DUP, "", 0},
+		{token.OPERATION, opcode.DROP, "", 0},
+	})
+
+	if l.ErrorCount() != 0 {
+		t.Errorf("Unexpected errors %v", l.Errors())
+	}
+}
+
+func TestLiterals(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "payload.bin"), []byte{0x00, 0xff, 0x0a}, 0644)
+
+	tests := []struct {
+		script string
+		data   string
+	}{
+		{`"abc"`, "616263"},
+		{`""`, ""},
+		{`"a\"b"`, "612262"},
+		{`"\x00\xff\n"`, "00ff0a"},
+		{`"\u00e9"`, "c3a9"},
+		{"`a\\b`", "615c62"},
+		{`'A'`, "41"},
+		{`'\x80'`, "80"},
+		{`[]`, ""},
+		{`[0 1 255]`, "0001ff"},
+		{`ADDRESS "16UwLL9Risc3QfPqBUvKofHmBQ7wMtjvM"`, "010966776006953d5567439e5e39f86a0d273bee"},
+		{`ADDRESS "mfcSEPR8EkJrpX91YkTJ9iscdAzppJrG9j"`, "010966776006953d5567439e5e39f86a0d273bee"},
+		{`@"payload.bin"`, "00ff0a"},
+	}
+
+	for _, test := range tests {
+		var l Lexer
+		l.InitFile(strings.NewReader(test.script), filepath.Join(dir, "main.script"), nil)
+
+		tok := l.Scan()
+		if tok != token.DATA || hex.EncodeToString(l.Data()) != test.data {
+			t.Errorf("%s expected DATA %s got %s %x", test.script, test.data, tok, l.Data())
+		}
+
+		if tok = l.Scan(); tok != token.ENDOFSCRIPT {
+			t.Errorf("%s expected ENDOFSCRIPT got %s", test.script, tok)
+		}
+
+		if l.ErrorCount() != 0 {
+			t.Errorf("%s unexpected errors %v", test.script, l.Errors())
+		}
+	}
+}
+
+func TestLiteralErrors(t *testing.T) {
+	tests := []string{
+		`'ab'`,
+		`"\q"`,
+		`"abc`,
+		`[256]`,
+		`[0x10]`,
+		`[-1]`,
+		`[DUP]`,
+		`[1 2`,
+		`ADDRESS "16UwLL9Risc3QfPqBUvKofHmBQ7wMtjvN"`,
+		`ADDRESS "0OIl"`,
+		`ADDRESS 1`,
+		`@"missing.bin"`,
+		`@payload`,
+	}
+
+	for _, script := range tests {
+		var l Lexer
+		l.Init(strings.NewReader(script), nil)
+		scanAll(&l)
+
+		if l.ErrorCount() != 1 {
+			t.Errorf("%s expected 1 error got %v", script, l.Errors())
+		}
+	}
+}
+
+func TestExplicitByteCode(t *testing.T) {
+	tests := []struct {
+		script   string
+		byteCode string
+		errors   int
+	}{
+		{"PUSHDATA1 0x07", "4c0107", 0},
+		{"PUSHDATA2 0x0708", "4d02000708", 0},
+		{"PUSHDATA4 0x07", "4e0100000007", 0},
+		{"PUSHDATA1 0x" + strings.Repeat("00", 256), "", 1},
+		{"BYTECODE 0xbaff", "baff", 0},
+		{"PUSHDATA1 DUP", "", 1},
+		{"BYTECODE 1", "", 1},
+		{"BYTECODE", "", 1},
+	}
+
+	for _, test := range tests {
+		var l Lexer
+		l.Init(strings.NewReader(test.script), nil)
+
+		tok := l.Scan()
+		if tok != token.BYTECODE || hex.EncodeToString(l.Data()) != test.byteCode {
+			t.Errorf("%s expected BYTECODE %s got %s %x", test.script, test.byteCode, tok, l.Data())
+		}
+
+		if l.ErrorCount() != test.errors {
+			t.Errorf("%s expected %d errors got %d", test.script, test.errors, l.ErrorCount())
+		}
+	}
+}
+
+func TestReferenceLexer(t *testing.T) {
+	script := `0x4c 0x01 0x07  'Az'
+OP_DUP 1ADD 2DUP -1 2147483648 NOTIF ENDIF OP_CHECKLOCKTIMEVERIFY BAD`
+
+	var l ReferenceLexer
+
+	errorLine := 0
+	l.Init(strings.NewReader(script), func(pos scanner.Position, msg string) {
+		errorLine = pos.Line
+	})
+
+	tests := []struct {
+		tok    token.Token
+		op     opcode.OpCode
+		data   string
+		number int64
+	}{
+		{token.BYTECODE, opcode.INVALID, "4c", 0},
+		{token.BYTECODE, opcode.INVALID, "01", 0},
+		{token.BYTECODE, opcode.INVALID, "07", 0},
+		{token.DATA, opcode.INVALID, "417a", 0},
+		{token.OPERATION, opcode.DUP, "", 0},
+		{token.OPERATION, opcode.ONEADD, "", 0},
+		{token.OPERATION, opcode.TWODUP, "", 0},
+		{token.NUMBER, opcode.INVALID, "", -1},
+		{token.NUMBER, opcode.INVALID, "", 2147483648},
+		{token.NOTIF, opcode.INVALID, "", 0},
+		{token.ENDIF, opcode.INVALID, "", 0},
+		{token.OPERATION, opcode.CHECKLOCKTIMEVERIFY, "", 0},
+		{token.OPERATION, opcode.INVALID, "", 0},
+		{token.ENDOFSCRIPT, opcode.INVALID, "", 0},
+		{token.ENDOFSCRIPT, opcode.INVALID, "", 0},
+	}
+
+	for i, test := range tests {
+		tok := l.Scan()
+		if tok != test.tok || l.Op() != test.op || hex.EncodeToString(l.Data()) != test.data {
+			t.Errorf("Failed %v tok %s op %s data %x", i, tok, l.Op(), l.Data())
+		}
+		if tok == token.NUMBER && l.Number().Int64() != test.number {
+			t.Errorf("Failed %v number %v", i, l.Number())
+		}
+	}
+
+	if l.ErrorCount() != 1 || errorLine != 2 {
+		t.Errorf("Failed ErrorCount() %d line %d", l.ErrorCount(), errorLine)
+	}
+}
+
+func TestReferenceLexerPositions(t *testing.T) {
+	script := "0x4c  'Az'\r\n\tOP_DUP 1ADD\n"
+
+	var l ReferenceLexer
+	l.Init(strings.NewReader(script), nil)
+
+	expected := []struct {
+		word   string
+		line   int
+		column int
+	}{
+		{"0x4c", 1, 1},
+		{"'Az'", 1, 7},
+		{"OP_DUP", 2, 2},
+		{"1ADD", 2, 9},
+	}
+
+	for _, test := range expected {
+		l.Scan()
+		if l.Pos() != strings.Index(script, test.word) {
+			t.Errorf("%s expected offset %d got %d", test.word, strings.Index(script, test.word), l.Pos())
+		}
+		if l.Position().Line != test.line || l.Position().Column != test.column || l.Position().Offset != l.Pos() {
+			t.Errorf("%s expected %d:%d got %s", test.word, test.line, test.column, l.Position())
+		}
+	}
+}
+
 func BenchmarkStandardTransactionToBitcoinAddress(b *testing.B) {
 	script := `
 DUP
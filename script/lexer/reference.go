@@ -0,0 +1,234 @@
+package lexer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"github.com/spearson78/guardian/script/opcode"
+	"github.com/spearson78/guardian/script/token"
+	"io"
+	"math/big"
+	"strings"
+	"text/scanner"
+	"unicode"
+)
+
+// Names used by the reference client that differ from ours.
+var referenceNames = map[string]opcode.OpCode{
+	"1ADD":          opcode.ONEADD,
+	"1SUB":          opcode.ONESUB,
+	"2MUL":          opcode.TWOMUL,
+	"2DIV":          opcode.TWODIV,
+	"0NOTEQUAL":     opcode.ZERONOTEQUAL,
+	"2DROP":         opcode.TWODROP,
+	"2DUP":          opcode.TWODUP,
+	"3DUP":          opcode.THREEDUP,
+	"2OVER":         opcode.TWOOVER,
+	"2ROT":          opcode.TWOROT,
+	"2SWAP":         opcode.TWOSWAP,
+	"INVALIDOPCODE": opcode.INVALID,
+}
+
+// ReferenceLexer reads the script notation of the reference client's test vectors such as script_tests.json.
+// Words are separated by whitespace, decimal numbers and 'quoted' strings are pushed, 0x prefixed hex is
+// inserted into the script as raw bytecode and operations may carry an OP_ prefix.
+type ReferenceLexer struct {
+	s          *bufio.Scanner
+	line       int
+	offset     int
+	words      []word
+	pos        scanner.Position
+	errorCount int
+	err        ErrorHandler
+
+	op     opcode.OpCode
+	data   []byte
+	number *big.Int
+}
+
+// word is a whitespace separated word of the source and where it starts.
+type word struct {
+	text   string
+	offset int
+	column int
+}
+
+func (l *ReferenceLexer) Init(r io.Reader, err ErrorHandler) {
+	l.s = bufio.NewScanner(r)
+	l.s.Split(scanLinesWithEnd)
+	l.line = 0
+	l.offset = 0
+	l.words = nil
+	l.pos = scanner.Position{}
+	l.errorCount = 0
+	l.err = err
+}
+
+// scanLinesWithEnd splits lines like bufio.ScanLines but keeps the line ending so offsets can be counted.
+func scanLinesWithEnd(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		return i + 1, data[:i+1], nil
+	}
+
+	if atEOF {
+		return len(data), data, nil
+	}
+
+	return 0, nil, nil
+}
+
+func (l *ReferenceLexer) raiseError(msg string) {
+	l.errorCount++
+	if l.err != nil {
+		l.err(l.pos, msg)
+	}
+}
+
+// Pos returns the byte offset of the start of the last word, the Offset of Position, as Lexer.Pos does.
+func (l *ReferenceLexer) Pos() int {
+	return l.pos.Offset
+}
+
+// Position returns the line and column of the start of the last word.
+func (l *ReferenceLexer) Position() scanner.Position {
+	return l.pos
+}
+
+func (l *ReferenceLexer) Op() opcode.OpCode {
+	return l.op
+}
+
+func (l *ReferenceLexer) Data() []byte {
+	return l.data
+}
+
+func (l *ReferenceLexer) Number() *big.Int {
+	return l.number
+}
+
+func (l *ReferenceLexer) ErrorCount() int {
+	return l.errorCount
+}
+
+func (l *ReferenceLexer) nextWord() (string, bool) {
+	for len(l.words) == 0 {
+		if !l.s.Scan() {
+			if l.s.Err() != nil {
+				l.raiseError("Read Failed " + l.s.Err().Error())
+			}
+			return "", false
+		}
+		l.line++
+		l.words = splitWords(l.s.Text(), l.offset)
+		l.offset += len(l.s.Bytes())
+	}
+
+	next := l.words[0]
+	l.words = l.words[1:]
+	l.pos = scanner.Position{Offset: next.offset, Line: l.line, Column: next.column}
+	return next.text, true
+}
+
+// splitWords splits line at whitespace like strings.Fields, recording where each word starts.
+// lineOffset is the byte offset of the start of line in the source.
+func splitWords(line string, lineOffset int) []word {
+	var words []word
+
+	start := -1
+	column := 0
+	startColumn := 0
+	for i, c := range line {
+		column++
+		if unicode.IsSpace(c) {
+			if start >= 0 {
+				words = append(words, word{text: line[start:i], offset: lineOffset + start, column: startColumn})
+				start = -1
+			}
+		} else if start < 0 {
+			start = i
+			startColumn = column
+		}
+	}
+
+	if start >= 0 {
+		words = append(words, word{text: line[start:], offset: lineOffset + start, column: startColumn})
+	}
+
+	return words
+}
+
+func isDecimal(s string) bool {
+	s = strings.TrimPrefix(s, "-")
+	if len(s) == 0 {
+		return false
+	}
+
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (l *ReferenceLexer) Scan() (tok token.Token) {
+
+	var err error
+
+	tok = token.INVALID
+	l.op = opcode.INVALID
+	l.data = nil
+	l.number = nil
+
+	word, ok := l.nextWord()
+	if !ok {
+		return token.ENDOFSCRIPT
+	}
+
+	switch {
+	case isDecimal(word):
+		tok = token.NUMBER
+		l.number = new(big.Int)
+		l.number.SetString(word, 10)
+	case strings.HasPrefix(word, "0x"):
+		tok = token.BYTECODE
+		l.data, err = hex.DecodeString(word[2:])
+		if err != nil || len(l.data) == 0 {
+			l.raiseError("Hex Decode Failed " + word)
+		}
+	case len(word) >= 2 && word[0] == '\'' && word[len(word)-1] == '\'':
+		tok = token.DATA
+		l.data = []byte(word[1 : len(word)-1])
+	default:
+		name := strings.TrimPrefix(word, "OP_")
+		switch name {
+		case "IF":
+			tok = token.IF
+		case "NOTIF":
+			tok = token.NOTIF
+		case "ELSE":
+			tok = token.ELSE
+		case "ENDIF":
+			tok = token.ENDIF
+		case "CODESEPARATOR":
+			tok = token.CODESEPARATOR
+		default:
+			tok = token.OPERATION
+			op, found := referenceNames[name]
+			if !found {
+				op = opcode.Parse(name)
+				if op == opcode.INVALID {
+					l.raiseError("Invalid Operation " + word)
+				}
+			}
+			l.op = op
+		}
+	}
+
+	return
+}
@@ -0,0 +1,15 @@
+// Package limits holds the consensus resource limits of the reference client's script interpreter.
+package limits
+
+const (
+	// Maximum size of a script in bytes
+	MaxScriptSize = 10000
+	// Maximum size of a single data push in bytes
+	MaxPushSize = 520
+	// Maximum number of non push operations including those in unexecuted branches
+	MaxOps = 201
+	// Maximum combined depth of the stack and alt stack
+	MaxStackSize = 1000
+	// Maximum size in bytes of a numeric operand
+	MaxNumberSize = 4
+)
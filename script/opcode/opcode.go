@@ -110,6 +110,10 @@ const (
 	NOP8      OpCode = 0xb7
 	NOP9      OpCode = 0xb8
 	NOP10     OpCode = 0xb9
+
+	//Soft fork redefinitions of reserved NOPs
+	CHECKLOCKTIMEVERIFY OpCode = NOP2
+	CHECKSEQUENCEVERIFY OpCode = NOP3
 )
 
 var opcodes = [...]string{
@@ -218,12 +222,20 @@ var opcodes = [...]string{
 	NOP10:     "NOP10",
 }
 
+var aliases = map[string]OpCode{
+	"CHECKLOCKTIMEVERIFY": CHECKLOCKTIMEVERIFY,
+	"CHECKSEQUENCEVERIFY": CHECKSEQUENCEVERIFY,
+}
+
 var lookup map[string]OpCode = make(map[string]OpCode)
 
 func init() {
 	for opcode, name := range opcodes {
 		lookup[name] = OpCode(opcode)
 	}
+	for name, opcode := range aliases {
+		lookup[name] = opcode
+	}
 }
 
 func Parse(s string) OpCode {
@@ -241,7 +253,8 @@ func (this OpCode) IsFlow() bool {
 }
 
 func (this OpCode) IsStack() bool {
-	return this == FROMALTSTACK ||
+	return this == TOALTSTACK ||
+		this == FROMALTSTACK ||
 		this == IFDUP ||
 		this == DEPTH ||
 		this == DROP ||
@@ -288,6 +301,7 @@ func (this OpCode) IsArithmetic() bool {
 		this == TWODIV ||
 		this == NEGATE ||
 		this == ABS ||
+		this == NOT ||
 		this == ZERONOTEQUAL ||
 		this == ADD ||
 		this == SUB ||
@@ -346,6 +360,25 @@ func (this OpCode) IsReserved() bool {
 		this == RESERVED2
 }
 
+// IsDisabled reports whether the reference client rejects any script containing the operation.
+func (this OpCode) IsDisabled() bool {
+	return this == CAT ||
+		this == SUBSTR ||
+		this == LEFT ||
+		this == RIGHT ||
+		this == INVERT ||
+		this == AND ||
+		this == OR ||
+		this == XOR ||
+		this == TWOMUL ||
+		this == TWODIV ||
+		this == MUL ||
+		this == DIV ||
+		this == MOD ||
+		this == LSHIFT ||
+		this == RSHIFT
+}
+
 func (this OpCode) IsNop() bool {
 	return this == NOP ||
 		this == NOP1 ||
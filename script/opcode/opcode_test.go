@@ -0,0 +1,17 @@
+package opcode
+
+import (
+	"testing"
+)
+
+func TestToAltStackIsStack(t *testing.T) {
+	if !TOALTSTACK.IsStack() || !TOALTSTACK.IsValid() {
+		t.Errorf("TOALTSTACK must be a valid stack operation")
+	}
+}
+
+func TestNotIsArithmetic(t *testing.T) {
+	if !NOT.IsArithmetic() || !NOT.IsValid() {
+		t.Errorf("NOT must be a valid arithmetic operation")
+	}
+}
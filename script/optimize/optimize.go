@@ -0,0 +1,171 @@
+// Package optimize rewrites parsed scripts into shorter scripts that execute the same way.
+//
+// An optimized script leaves the same stack as the original and fails whenever the original fails,
+// with two exceptions: it contains fewer operations, so a script whose executed CHECKMULTISIG keys
+// take it over the operation limit may fit within it after optimization, and its pushes are minimal.
+// Script rejects input that breaks the script size, push size or operation limits before execution
+// starts, as optimizing could otherwise remove the cause. Signatures commit to the script, they have
+// to be made against the optimized script.
+package optimize
+
+import (
+	"github.com/spearson78/guardian/script/ast"
+	"github.com/spearson78/guardian/script/compiler"
+	"github.com/spearson78/guardian/script/executor"
+	"github.com/spearson78/guardian/script/limits"
+	"github.com/spearson78/guardian/script/opcode"
+	"github.com/spearson78/guardian/script/scanner"
+	"math/big"
+)
+
+// verifyForms maps each operation to the operation equivalent to it followed by VERIFY.
+var verifyForms = map[opcode.OpCode]opcode.OpCode{
+	opcode.EQUAL:         opcode.EQUALVERIFY,
+	opcode.NUMEQUAL:      opcode.NUMEQUALVERIFY,
+	opcode.CHECKSIG:      opcode.CHECKSIGVERIFY,
+	opcode.CHECKMULTISIG: opcode.CHECKMULTISIGVERIFY,
+}
+
+// Script parses, optimizes and recompiles bytecode.
+// Scripts that break executor.DefaultLimits before execution are rejected with the executor's error.
+func Script(script []byte) ([]byte, error) {
+	_, err := executor.CheckScriptLimits(script, executor.DefaultLimits)
+	if err != nil {
+		return nil, err
+	}
+
+	s := new(scanner.Scanner)
+	s.Init(script, nil)
+
+	block, err := ast.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+
+	Block(block)
+
+	return compiler.CompileBlock(block)
+}
+
+// Block rewrites block and every block nested in it in place:
+//
+//	nodes following RETURN in the same block are removed
+//	EQUAL, NUMEQUAL, CHECKSIG and CHECKMULTISIG followed by VERIFY become their VERIFY forms
+//	NOP is removed
+//	data pushes of -1 to 16 become numbers so they compile to the single byte operations
+//
+// Positions of rewritten nodes still refer to the original script.
+// Unlike Script, Block does not check the limits, removed operations may hide a script that is too large.
+func Block(block ast.Block) {
+	var optimized []ast.Node
+
+	for i, node := range block.List() {
+		switch n := node.(type) {
+		case *ast.Operation:
+			if n.OpCode == opcode.NOP {
+				continue
+			}
+
+			if n.OpCode == opcode.VERIFY && len(optimized) != 0 {
+				if previous, ok := optimized[len(optimized)-1].(*ast.Operation); ok {
+					if verifyForm, ok := verifyForms[previous.OpCode]; ok {
+						previous.OpCode = verifyForm
+						continue
+					}
+				}
+			}
+
+			optimized = append(optimized, n)
+
+			//RETURN always fails so nothing after it in this block can run.
+			//Operations and pushes that fail a script even when unexecuted must be kept.
+			if n.OpCode == opcode.RETURN && !failsUnexecuted(block.List()[i+1:]) {
+				setList(block, optimized)
+				return
+			}
+		case *ast.Data:
+			if number := smallNumber(n.Value); number != nil {
+				optimized = append(optimized, &ast.Number{
+					ParentBlock: n.ParentBlock,
+					NumberPos:   n.DataPos,
+					Value:       number,
+				})
+				continue
+			}
+
+			optimized = append(optimized, n)
+		case *ast.IfStmt:
+			Block(n.Body)
+			for _, elseBlock := range n.Else {
+				Block(elseBlock)
+			}
+
+			optimized = append(optimized, n)
+		case ast.Block:
+			Block(n)
+			optimized = append(optimized, n)
+		default:
+			optimized = append(optimized, n)
+		}
+	}
+
+	setList(block, optimized)
+}
+
+func setList(block ast.Block, nodes []ast.Node) {
+	switch b := block.(type) {
+	case *ast.SimpleBlock:
+		b.NodeList = nodes
+	case *ast.CodeSeparator:
+		b.NodeList = nodes
+	}
+}
+
+// smallNumber returns the number whose single byte operation pushes data, or nil if there is none.
+// The empty push is already a single byte.
+func smallNumber(data []byte) *big.Int {
+	if len(data) != 1 {
+		return nil
+	}
+
+	switch {
+	case data[0] >= 1 && data[0] <= 16:
+		return big.NewInt(int64(data[0]))
+	case data[0] == 0x81:
+		return big.NewInt(-1)
+	}
+
+	return nil
+}
+
+// failsUnexecuted reports whether nodes contain an operation or push that fails the script wherever it appears.
+func failsUnexecuted(nodes []ast.Node) bool {
+	for _, node := range nodes {
+		switch n := node.(type) {
+		case *ast.Data:
+			if len(n.Value) > limits.MaxPushSize {
+				return true
+			}
+		case *ast.Operation:
+			if n.OpCode == opcode.VERIF || n.OpCode == opcode.VERNOTIF || n.OpCode.IsDisabled() {
+				return true
+			}
+		case *ast.IfStmt:
+			if failsUnexecuted(n.Body.List()) {
+				return true
+			}
+
+			for _, elseBlock := range n.Else {
+				if failsUnexecuted(elseBlock.List()) {
+					return true
+				}
+			}
+		case ast.Block:
+			if failsUnexecuted(n.List()) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
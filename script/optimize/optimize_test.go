@@ -0,0 +1,234 @@
+package optimize
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"github.com/spearson78/guardian/script/ast"
+	"github.com/spearson78/guardian/script/compiler"
+	"github.com/spearson78/guardian/script/executor"
+	"github.com/spearson78/guardian/script/scanner"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+var (
+	//A push over the 520 byte limit
+	oversizedPush = "4d0902" + strings.Repeat("aa", 521)
+	//Pushes of 520 bytes totalling more than the 10000 byte script limit
+	oversizedScript = strings.Repeat("4d0802"+strings.Repeat("bb", 520), 20)
+)
+
+func TestScript(t *testing.T) {
+	tests := []struct {
+		script    string
+		optimized string
+	}{
+		{"", ""},
+		{"76a914010966776006953d5567439e5e39f86a0d273bee88ac", "76a914010966776006953d5567439e5e39f86a0d273bee88ac"},
+		//VERIFY forms
+		{"8769", "88"},
+		{"9c69", "9d"},
+		{"ac69", "ad"},
+		{"ae69", "af"},
+		{"876169", "88"},
+		{"876969", "8869"},
+		{"7669", "7669"},
+		{"87ab69", "87ab69"},
+		{"876369676968", "876369676968"},
+		//NOP
+		{"61", ""},
+		{"516161", "51"},
+		{"6361676168", "636768"},
+		//Dead code
+		{"6a51", "6a"},
+		{"516a6351686a", "516a"},
+		{"63516a527667516a5168", "63516a67516a68"},
+		{"ab6a51", "ab6a"},
+		{"6aab51", "6a"},
+		{"6a7e", "6a7e"},
+		{"6a63657e68", "6a63657e68"},
+		//Numbers
+		{"0101", "51"},
+		{"0110", "60"},
+		{"0181", "4f"},
+		{"4c0105", "55"},
+		{"4c00", "00"},
+		{"0111", "0111"},
+		{"0100", "0100"},
+		{"0180", "0180"},
+		{"4c020102", "020102"},
+	}
+
+	for _, test := range tests {
+		script, _ := hex.DecodeString(test.script)
+
+		optimized, err := Script(script)
+		if err != nil {
+			t.Errorf("%s Failed %v", test.script, err)
+			continue
+		}
+
+		if hex.EncodeToString(optimized) != test.optimized {
+			t.Errorf("%s expected %s got %x", test.script, test.optimized, optimized)
+		}
+	}
+
+	for _, script := range []string{"63", "68", "4c"} {
+		data, _ := hex.DecodeString(script)
+		if _, err := Script(data); err == nil {
+			t.Errorf("%s expected error", script)
+		}
+	}
+
+	//Limits broken in dead code would otherwise be removed
+	limitTests := []struct {
+		script string
+		err    error
+	}{
+		{"00636a" + oversizedPush + "6851", executor.ErrPushSize},
+		{"00636a" + oversizedScript + "6851", executor.ErrScriptSize},
+		{strings.Repeat("61", 10001), executor.ErrScriptSize},
+		{strings.Repeat("61", 202), executor.ErrOpCount},
+	}
+
+	for _, test := range limitTests {
+		data, _ := hex.DecodeString(test.script)
+		if _, err := Script(data); err != test.err {
+			t.Errorf("%.40s expected %v got %v", test.script, test.err, err)
+		}
+	}
+}
+
+func TestBlockKeepsOversizedPush(t *testing.T) {
+	script, _ := hex.DecodeString("00636a" + oversizedPush + "6851")
+
+	s := new(scanner.Scanner)
+	s.Init(script, nil)
+	block, err := ast.Parse(s)
+	if err != nil {
+		t.Fatalf("Parse failed %v", err)
+	}
+
+	Block(block)
+
+	optimized, err := compiler.CompileBlock(block)
+	if err != nil || !bytes.Equal(optimized, script) {
+		t.Errorf("Expected the oversized push to be kept got %.40x %v", optimized, err)
+	}
+}
+
+// mockCheckSig accepts every non empty signature and ignores the subscript, which optimizing changes.
+type mockCheckSig struct{}
+
+func (this mockCheckSig) CheckSig(pk []byte, hashType uint32, sig []byte, subScript []byte) error {
+	if len(sig) == 0 {
+		return errors.New("Empty Signature")
+	}
+
+	return nil
+}
+
+type executeResult struct {
+	err       error
+	stack     [][]byte
+	checkSigs int
+}
+
+func execute(script []byte) executeResult {
+	e := new(executor.Executor)
+	e.Init(mockCheckSig{})
+	result, err := e.Execute(script)
+
+	var stack [][]byte
+	for i := e.Depth() - 1; i >= 0; i-- {
+		data, _ := e.PeekN(i)
+		stack = append(stack, data)
+	}
+
+	return executeResult{err: err, stack: stack, checkSigs: len(result.CheckSigs)}
+}
+
+// compareOptimized requires the optimized script to succeed or fail with the original leaving the same stack.
+func compareOptimized(t *testing.T, script []byte) {
+	original := execute(script)
+
+	optimizedScript, err := Script(script)
+	if err != nil {
+		if original.err == nil {
+			t.Fatalf("%x executed but did not optimize %v", script, err)
+		}
+		return
+	}
+
+	//Fewer operations may bring a script whose executed CHECKMULTISIG keys exceed the limit within it
+	if original.err == executor.ErrOpCount {
+		return
+	}
+
+	optimized := execute(optimizedScript)
+
+	if (original.err == nil) != (optimized.err == nil) {
+		t.Fatalf("%x optimized to %x original error %v optimized error %v", script, optimizedScript, original.err, optimized.err)
+	}
+
+	if original.err != nil {
+		return
+	}
+
+	if len(original.stack) != len(optimized.stack) {
+		t.Fatalf("%x optimized to %x original stack %x optimized stack %x", script, optimizedScript, original.stack, optimized.stack)
+	}
+
+	for i := range original.stack {
+		if !bytes.Equal(original.stack[i], optimized.stack[i]) {
+			t.Fatalf("%x optimized to %x original stack %x optimized stack %x", script, optimizedScript, original.stack, optimized.stack)
+		}
+	}
+
+	if original.checkSigs != optimized.checkSigs {
+		t.Fatalf("%x optimized to %x original signature checks %d optimized %d", script, optimizedScript, original.checkSigs, optimized.checkSigs)
+	}
+
+	if len(optimizedScript) > len(script) {
+		t.Fatalf("%x optimized to longer %x", script, optimizedScript)
+	}
+}
+
+// Fragments favouring the rewritten patterns.
+var optimizeFragments = []string{
+	"00", "51", "52", "60", "4f", "0101", "0110", "0181", "0111", "0100", "4c0103", "4c00", "020203",
+	"61", "87", "69", "8769", "9c", "9c69", "ac", "ac69", "ae", "ae69", "6a", "6a7e", "65",
+	"63", "64", "67", "68", "6351", "5163", "0063", "68",
+	"76", "75", "93", "7c", "82", "a8", "ab", "6b", "6c",
+	oversizedPush, oversizedScript,
+}
+
+func randomOptimizeScript(r *rand.Rand) []byte {
+	var script []byte
+	for n := r.Intn(16); n >= 0; n-- {
+		fragment, _ := hex.DecodeString(optimizeFragments[r.Intn(len(optimizeFragments))])
+		script = append(script, fragment...)
+	}
+	return script
+}
+
+func TestOptimizeRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 50000; i++ {
+		compareOptimized(t, randomOptimizeScript(r))
+	}
+}
+
+func FuzzOptimize(f *testing.F) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 32; i++ {
+		f.Add(randomOptimizeScript(r))
+	}
+
+	f.Fuzz(func(t *testing.T, script []byte) {
+		compareOptimized(t, script)
+	})
+}
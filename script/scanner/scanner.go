@@ -26,7 +26,14 @@ var _EMPTY_SLICE = []byte{}
 func (s *Scanner) Init(script []byte, err ErrorHandler) {
 	s.script = script
 	s.pos = 0
+	s.endByteCodePos = 0
+	s.errorCount = 0
 	s.err = err
+
+	s.op = opcode.INVALID
+	s.byteCode = 0
+	s.data = nil
+	s.number = nil
 }
 
 func (s *Scanner) raiseError(msg string) {
@@ -52,6 +59,34 @@ func (s *Scanner) ByteCode() byte {
 	return s.byteCode
 }
 
+// IsMinimalPush reports whether the last DATA token used the shortest push for its data.
+func (s *Scanner) IsMinimalPush() bool {
+	return IsMinimalPush(s.byteCode, s.data)
+}
+
+// IsMinimalPush reports whether byteCode is the shortest push operation for data as required by BIP62.
+// Empty data and single bytes representing -1 to 16 must use the small number operations.
+func IsMinimalPush(byteCode byte, data []byte) bool {
+	l := len(data)
+
+	switch {
+	case l == 0:
+		return byteCode == 0x00
+	case l == 1 && data[0] >= 1 && data[0] <= 16:
+		return byteCode == 0x50+data[0]
+	case l == 1 && data[0] == 0x81:
+		return byteCode == 0x4f
+	case l <= 75:
+		return int(byteCode) == l
+	case l <= 255:
+		return byteCode == 0x4c
+	case l <= 65535:
+		return byteCode == 0x4d
+	}
+
+	return true
+}
+
 func (s *Scanner) Data() []byte {
 	return s.data
 }
@@ -82,52 +117,16 @@ func (s *Scanner) Scan() (tok token.Token) {
 			tok = token.DATA
 			s.data = _EMPTY_SLICE
 		case bytecode <= byte(0x4b): //PUSH CONSTANT
-			tok = token.DATA
-			dataPos := s.pos + 1
-			endOfData := dataPos + int(bytecode)
-			if len(s.script) < endOfData {
-				s.raiseError("Script Underflow Push Constant")
-				endOfData = len(s.script)
-			}
-			s.data = s.script[dataPos:endOfData]
-			s.pos = s.pos + int(bytecode)
+			tok = s.scanPush(s.pos+1, uint64(bytecode), "Script Underflow Push Constant")
 		case bytecode == 0x4c: //PUSHDATA1
-			tok = token.DATA
-			var byteCount = int(s.script[s.pos+1])
-			dataPos := s.pos + 2
-
-			endOfData := dataPos + byteCount
-			if len(s.script) < endOfData {
-				s.raiseError("Script Underflow PushData1")
-				endOfData = len(s.script)
-			}
-			s.data = s.script[dataPos:endOfData]
-
-			s.pos = s.pos + byteCount + 1
+			tok = s.scanPushData(1, "PushData1")
 		case bytecode == 0x4d: //PUSHDATA2
-			tok = token.DATA
-			byteCount := uint16(s.script[s.pos+1]) | uint16(s.script[s.pos+2]<<8)
-			dataPos := s.pos + 3
-
-			endOfData := dataPos + int(byteCount)
-			if len(s.script) < endOfData {
-				s.raiseError("Script Underflow PushData2")
-				endOfData = len(s.script)
-			}
-			s.data = s.script[dataPos:endOfData]
-
-			s.pos = s.pos + int(byteCount) + 2
+			tok = s.scanPushData(2, "PushData2")
 		case bytecode == 0x4e: //PUSHDATA4
-			tok = token.DATA
-			byteCount := uint32(s.script[s.pos+1]) | uint32(s.script[s.pos+2]<<8) | uint32(s.script[s.pos+3]<<16) | uint32(s.script[s.pos+4]<<24)
-			dataPos := s.pos + 5
-			endOfData := dataPos + int(byteCount)
-			if len(s.script) < endOfData {
-				s.raiseError("Script Underflow PushData4")
-				endOfData = len(s.script)
-			}
-			s.data = s.script[dataPos:endOfData]
-			s.pos = s.pos + int(byteCount) + 2
+			tok = s.scanPushData(4, "PushData4")
+		case bytecode == 0x50: //RESERVED
+			tok = token.OPERATION
+			s.op = opcode.RESERVED
 		case bytecode == 0x4f: //1Negate
 			tok = token.NUMBER
 			s.number = big.NewInt(-1)
@@ -148,11 +147,9 @@ func (s *Scanner) Scan() (tok token.Token) {
 		case bytecode == 0x68: //ENDIF
 			tok = token.ENDIF
 		default:
-			tok = token.INVALID
-			s.op = opcode.INVALID
-			s.data = nil
-			s.number = nil
-			s.raiseError("Invalid Token")
+			//Undefined opcodes are only invalid if they are executed
+			tok = token.OPERATION
+			s.op = opcode.OpCode(bytecode)
 		}
 	}
 
@@ -164,3 +161,36 @@ func (s *Scanner) Scan() (tok token.Token) {
 
 	return
 }
+
+// scanPushData reads the little endian length of a PUSHDATA operation followed by the data.
+func (s *Scanner) scanPushData(width int, name string) token.Token {
+	lengthPos := s.pos + 1
+	if len(s.script)-lengthPos < width {
+		s.raiseError("Script Underflow " + name + " Length")
+		s.pos = len(s.script) - 1
+		return token.INVALID
+	}
+
+	var byteCount uint64
+	for i := width - 1; i >= 0; i-- {
+		byteCount = byteCount<<8 | uint64(s.script[lengthPos+i])
+	}
+
+	return s.scanPush(lengthPos+width, byteCount, "Script Underflow "+name)
+}
+
+// scanPush reads byteCount bytes of data starting at dataPos and leaves pos on the last byte of the push.
+// Data running past the end of the script is truncated and reported.
+func (s *Scanner) scanPush(dataPos int, byteCount uint64, underflow string) token.Token {
+	endOfData := len(s.script)
+	if uint64(len(s.script)-dataPos) < byteCount {
+		s.raiseError(underflow)
+	} else {
+		endOfData = dataPos + int(byteCount)
+	}
+
+	s.data = s.script[dataPos:endOfData]
+	s.pos = endOfData - 1
+
+	return token.DATA
+}
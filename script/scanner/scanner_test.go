@@ -5,6 +5,7 @@ import (
 	"encoding/hex"
 	"github.com/spearson78/guardian/script/opcode"
 	"github.com/spearson78/guardian/script/token"
+	"strings"
 	"testing"
 )
 
@@ -112,7 +113,7 @@ func TestUnderflow(t *testing.T) {
 
 }
 
-func TestInvalidToken(t *testing.T) {
+func TestUnknownOpCode(t *testing.T) {
 	script, _ := hex.DecodeString("76A91489ABCDEFABBAABBAABBAABBAABBAABBAABBAABBAFFAC")
 	checkData, _ := hex.DecodeString("89ABCDEFABBAABBAABBAABBAABBAABBAABBAABBA")
 
@@ -138,9 +139,10 @@ func TestInvalidToken(t *testing.T) {
 		t.Errorf("Failed DUP tok %s op %s bcPos %d endbcPos %d", tok, s.Op(), s.Pos(), s.EndPos())
 	}
 
+	//Undefined opcodes are scanned as operations, they only fail if executed
 	tok = s.Scan()
-	if tok != token.INVALID {
-		t.Errorf("Failed INVALID tok %s op %s", tok, s.Op())
+	if tok != token.OPERATION || s.Op() != opcode.OpCode(0xff) {
+		t.Errorf("Failed UNKNOWN tok %s op %s", tok, s.Op())
 	}
 
 	tok = s.Scan()
@@ -158,11 +160,11 @@ func TestInvalidToken(t *testing.T) {
 		t.Errorf("Failed double ENDOFSCRIPT tok %s", tok)
 	}
 
-	if !errorReported {
-		t.Errorf("Failed Not ErrorReported")
+	if errorReported {
+		t.Errorf("Failed ErrorReported")
 	}
 
-	if s.ErrorCount() != 1 {
+	if s.ErrorCount() != 0 {
 		t.Errorf("Failed ErrorCount() %d", s.ErrorCount())
 	}
 
@@ -227,6 +229,201 @@ func TestInvalidScript(t *testing.T) {
 	}
 }
 
+func TestIsMinimalPush(t *testing.T) {
+	tests := []struct {
+		script  string
+		minimal bool
+	}{
+		{"00", true},
+		{"0100", true},
+		{"0111", true},
+		{"0180", true},
+		{"0101", false},
+		{"0110", false},
+		{"0181", false},
+		{"4c00", false},
+		{"4c0111", false},
+		{"020102", true},
+		{"4c020102", false},
+		{"4b" + strings.Repeat("aa", 75), true},
+		{"4c4b" + strings.Repeat("aa", 75), false},
+		{"4c4c" + strings.Repeat("aa", 76), true},
+		{"4d4c00" + strings.Repeat("aa", 76), false},
+		{"4e0100000011", false},
+		{"4d0001" + strings.Repeat("aa", 256), true},
+		{"4e00010000" + strings.Repeat("aa", 256), false},
+	}
+
+	for _, test := range tests {
+		script, _ := hex.DecodeString(test.script)
+
+		var s Scanner
+		s.Init(script, nil)
+
+		tok := s.Scan()
+		if tok != token.DATA || s.ErrorCount() != 0 {
+			t.Errorf("%s Failed tok %s errors %d", test.script, tok, s.ErrorCount())
+			continue
+		}
+
+		if s.IsMinimalPush() != test.minimal {
+			t.Errorf("%s expected minimal %v", test.script, test.minimal)
+		}
+	}
+}
+
+func TestPushData(t *testing.T) {
+	tests := []struct {
+		header string
+		length int
+	}{
+		{"4c00", 0},
+		{"4cff", 255},
+		{"4d0000", 0},
+		{"4d0001", 256},
+		{"4d0102", 513},
+		{"4dff01", 511},
+		{"4e00000000", 0},
+		{"4e2c010000", 300},
+		{"4e00000100", 65536},
+	}
+
+	for _, test := range tests {
+		header, _ := hex.DecodeString(test.header)
+		data := bytes.Repeat([]byte{0xaa}, test.length)
+
+		//The operation following the push must be found where it is
+		script := append(append(header, data...), byte(opcode.DUP))
+
+		var s Scanner
+		s.Init(script, nil)
+
+		tok := s.Scan()
+		if tok != token.DATA || !bytes.Equal(s.Data(), data) || s.Pos() != len(header)+len(data) {
+			t.Errorf("%s Failed DATA tok %s len %d pos %d", test.header, tok, len(s.Data()), s.Pos())
+		}
+
+		tok = s.Scan()
+		if tok != token.OPERATION || s.Op() != opcode.DUP {
+			t.Errorf("%s Failed DUP tok %s op %s", test.header, tok, s.Op())
+		}
+
+		if s.Scan() != token.ENDOFSCRIPT || s.ErrorCount() != 0 {
+			t.Errorf("%s Failed ENDOFSCRIPT errors %d", test.header, s.ErrorCount())
+		}
+	}
+}
+
+// TestTruncation cuts every push form at every position and requires a single error with whatever data is present.
+func TestTruncation(t *testing.T) {
+	pushes := []struct {
+		header string
+		length int
+	}{
+		{"05", 5},
+		{"4b", 75},
+		{"4c05", 5},
+		{"4d0501", 261},
+		{"4e05010000", 261},
+	}
+
+	for _, push := range pushes {
+		header, _ := hex.DecodeString(push.header)
+		data := make([]byte, push.length)
+		for i := range data {
+			data[i] = byte(i)
+		}
+		script := append(header, data...)
+
+		for cut := 1; cut < len(script); cut++ {
+			var errorPos int
+			var s Scanner
+			s.Init(script[:cut], func(pos int, msg string) {
+				errorPos = pos
+			})
+
+			tok := s.Scan()
+
+			if cut < len(header) {
+				//The length itself is incomplete
+				if tok != token.INVALID {
+					t.Errorf("%s cut %d expected INVALID got %s", push.header, cut, tok)
+				}
+			} else if tok != token.DATA || !bytes.Equal(s.Data(), data[:cut-len(header)]) {
+				t.Errorf("%s cut %d Failed DATA tok %s data %x", push.header, cut, tok, s.Data())
+			}
+
+			if s.Pos() != cut || errorPos > cut {
+				t.Errorf("%s cut %d Failed pos %d error pos %d", push.header, cut, s.Pos(), errorPos)
+			}
+
+			if s.Scan() != token.ENDOFSCRIPT || s.ErrorCount() != 1 {
+				t.Errorf("%s cut %d Failed ErrorCount() %d", push.header, cut, s.ErrorCount())
+			}
+		}
+	}
+}
+
+func TestInitResets(t *testing.T) {
+	var s Scanner
+	s.Init([]byte{0x4c}, nil)
+	s.Scan()
+
+	s.Init([]byte{0x76}, nil)
+	if s.ErrorCount() != 0 || s.Data() != nil {
+		t.Errorf("Init did not reset the scanner")
+	}
+}
+
+// checkScan requires the tokens of script to cover it exactly, in order and without overlaps.
+func checkScan(t *testing.T, script []byte) {
+	var s Scanner
+	s.Init(script, nil)
+
+	start := 0
+	for tok := s.Scan(); tok != token.ENDOFSCRIPT; tok = s.Scan() {
+		end := s.Pos()
+		if end <= start || end > len(script) {
+			t.Fatalf("%x token %s from %d ends at %d", script, tok, start, end)
+		}
+
+		if tok == token.DATA && s.ErrorCount() == 0 {
+			raw := script[start:end]
+			if !bytes.Equal(raw[len(raw)-len(s.Data()):], s.Data()) {
+				t.Fatalf("%x token %s at %d data %x is not the end of %x", script, tok, start, s.Data(), raw)
+			}
+		}
+
+		start = end
+	}
+
+	if start != len(script) {
+		t.Fatalf("%x scanning stopped at %d", script, start)
+	}
+}
+
+func FuzzScan(f *testing.F) {
+	seeds := []string{
+		"",
+		"76a91489abcdefabbaabbaabbaabbaabbaabbaabbaabba88ac",
+		"4c",
+		"4c05",
+		"4d0501",
+		"4e05010000",
+		"4effffffff",
+		"4b",
+	}
+
+	for _, seed := range seeds {
+		script, _ := hex.DecodeString(seed)
+		f.Add(script)
+	}
+
+	f.Fuzz(func(t *testing.T, script []byte) {
+		checkScan(t, script)
+	})
+}
+
 func BenchmarkStandardTransactionToBitcoinAddress(b *testing.B) {
 	script, _ := hex.DecodeString("76A91489ABCDEFABBAABBAABBAABBAABBAABBAABBAABBA88AC")
 
@@ -0,0 +1,230 @@
+// Package template recognises the standard forms of scriptPubKey and the addresses they pay.
+package template
+
+import (
+	"github.com/spearson78/guardian/encoding/address"
+	"github.com/spearson78/guardian/script/opcode"
+	"github.com/spearson78/guardian/script/scanner"
+	"github.com/spearson78/guardian/script/token"
+	"strconv"
+)
+
+// MaxNullDataSize is the largest standard NullData script, RETURN and a PUSHDATA1 of 80 bytes as in the reference client.
+const MaxNullDataSize = 83
+
+type Class byte
+
+const (
+	NonStandard Class = iota
+	PubKey
+	PubKeyHash
+	ScriptHash
+	MultiSig
+	NullData
+)
+
+var classes = [...]string{
+	NonStandard: "NonStandard",
+	PubKey:      "PubKey",
+	PubKeyHash:  "PubKeyHash",
+	ScriptHash:  "ScriptHash",
+	MultiSig:    "MultiSig",
+	NullData:    "NullData",
+}
+
+func (this Class) String() string {
+	if int(this) >= len(classes) {
+		return "Class(" + strconv.Itoa(int(this)) + ")"
+	}
+	return classes[this]
+}
+
+// Network holds the address version bytes of a bitcoin network.
+type Network struct {
+	PubKeyHashVersion byte
+	ScriptHashVersion byte
+}
+
+var MainNet = Network{PubKeyHashVersion: 0x00, ScriptHashVersion: 0x05}
+var TestNet = Network{PubKeyHashVersion: 0x6f, ScriptHashVersion: 0xc4}
+
+// Template is the result of matching a scriptPubKey.
+// PublicKeys holds the key of a PubKey script and the keys of a MultiSig script in script order.
+// Hash holds the 20 byte hash of PubKeyHash and ScriptHash scripts.
+// Required is the number of signatures needed to spend, zero for NullData and NonStandard scripts.
+// Data holds the data pushed after the RETURN of a NullData script, small numbers are not included.
+type Template struct {
+	Class      Class
+	PublicKeys [][]byte
+	Hash       []byte
+	Required   int
+	Data       [][]byte
+}
+
+type scanned struct {
+	tok      token.Token
+	byteCode byte
+	op       opcode.OpCode
+	data     []byte
+}
+
+// scan splits script into tokens, it reports false if the scanner found an error.
+func scan(script []byte) ([]scanned, bool) {
+	s := new(scanner.Scanner)
+	s.Init(script, nil)
+
+	var tokens []scanned
+	for tok := s.Scan(); tok != token.ENDOFSCRIPT; tok = s.Scan() {
+		if tok == token.INVALID {
+			return nil, false
+		}
+
+		tokens = append(tokens, scanned{
+			tok:      tok,
+			byteCode: s.ByteCode(),
+			op:       s.Op(),
+			data:     s.Data(),
+		})
+	}
+
+	return tokens, s.ErrorCount() == 0
+}
+
+func (this scanned) isOp(op opcode.OpCode) bool {
+	return this.tok == token.OPERATION && this.op == op
+}
+
+// isPush reports a direct push of exactly n bytes, the only encoding used by the standard forms.
+func (this scanned) isPush(n int) bool {
+	return this.tok == token.DATA && n > 0 && n <= 75 && this.byteCode == byte(n)
+}
+
+// isPublicKey reports a push of a compressed, uncompressed or hybrid public key.
+// Like the reference client only the size and prefix are checked, hybrid 0x06 and 0x07 keys are accepted.
+func (this scanned) isPublicKey() bool {
+	switch {
+	case this.isPush(33):
+		return this.data[0] == 0x02 || this.data[0] == 0x03
+	case this.isPush(65):
+		return this.data[0] == 0x04 || this.data[0] == 0x06 || this.data[0] == 0x07
+	}
+
+	return false
+}
+
+// smallInt returns the value of OP_1 to OP_16.
+func (this scanned) smallInt() (int, bool) {
+	if this.tok != token.NUMBER || this.byteCode < 0x51 || this.byteCode > 0x60 {
+		return 0, false
+	}
+
+	return int(this.byteCode - 0x50), true
+}
+
+// Match classifies script and extracts its keys, hashes or data.
+// Scripts that are not exactly one of the standard forms are NonStandard.
+// NullData scripts larger than MaxNullDataSize are NonStandard.
+func Match(script []byte) *Template {
+	tokens, ok := scan(script)
+	if !ok {
+		return &Template{Class: NonStandard}
+	}
+
+	switch {
+	case len(tokens) == 2 && tokens[0].isPublicKey() && tokens[1].isOp(opcode.CHECKSIG):
+		return &Template{
+			Class:      PubKey,
+			PublicKeys: [][]byte{tokens[0].data},
+			Required:   1,
+		}
+	case len(tokens) == 5 && tokens[0].isOp(opcode.DUP) && tokens[1].isOp(opcode.HASH160) && tokens[2].isPush(20) &&
+		tokens[3].isOp(opcode.EQUALVERIFY) && tokens[4].isOp(opcode.CHECKSIG):
+		return &Template{
+			Class:    PubKeyHash,
+			Hash:     tokens[2].data,
+			Required: 1,
+		}
+	case len(tokens) == 3 && tokens[0].isOp(opcode.HASH160) && tokens[1].isPush(20) && tokens[2].isOp(opcode.EQUAL):
+		return &Template{
+			Class:    ScriptHash,
+			Hash:     tokens[1].data,
+			Required: 1,
+		}
+	case len(tokens) >= 1 && tokens[0].isOp(opcode.RETURN) && len(script) <= MaxNullDataSize:
+		if match, ok := matchNullData(tokens[1:]); ok {
+			return match
+		}
+	case len(tokens) >= 4 && tokens[len(tokens)-1].isOp(opcode.CHECKMULTISIG):
+		if match, ok := matchMultiSig(tokens[:len(tokens)-1]); ok {
+			return match
+		}
+	}
+
+	return &Template{Class: NonStandard}
+}
+
+// matchMultiSig matches m <keys...> n where 1 <= m <= n.
+func matchMultiSig(tokens []scanned) (*Template, bool) {
+	m, ok := tokens[0].smallInt()
+	if !ok {
+		return nil, false
+	}
+
+	n, ok := tokens[len(tokens)-1].smallInt()
+	if !ok || m > n || n != len(tokens)-2 {
+		return nil, false
+	}
+
+	keys := make([][]byte, 0, n)
+	for _, key := range tokens[1 : len(tokens)-1] {
+		if !key.isPublicKey() {
+			return nil, false
+		}
+		keys = append(keys, key.data)
+	}
+
+	return &Template{
+		Class:      MultiSig,
+		PublicKeys: keys,
+		Required:   m,
+	}, true
+}
+
+// matchNullData requires everything after the RETURN to be a push.
+func matchNullData(tokens []scanned) (*Template, bool) {
+	var data [][]byte
+	for _, push := range tokens {
+		//RESERVED is considered a push by the reference client
+		if push.byteCode > 0x60 {
+			return nil, false
+		}
+
+		if push.tok == token.DATA {
+			data = append(data, push.data)
+		}
+	}
+
+	return &Template{
+		Class: NullData,
+		Data:  data,
+	}, true
+}
+
+// Addresses returns the addresses paid by the script on network.
+// Keys are represented by the address of their hash so PubKey and MultiSig scripts also have addresses.
+func (this *Template) Addresses(network Network) []string {
+	switch this.Class {
+	case PubKey, MultiSig:
+		addresses := make([]string, len(this.PublicKeys))
+		for i, publicKey := range this.PublicKeys {
+			addresses[i] = address.EncodePublicKey(publicKey, network.PubKeyHashVersion)
+		}
+		return addresses
+	case PubKeyHash:
+		return []string{address.EncodePublicKeyHash(this.Hash, network.PubKeyHashVersion)}
+	case ScriptHash:
+		return []string{address.EncodePublicKeyHash(this.Hash, network.ScriptHashVersion)}
+	}
+
+	return nil
+}
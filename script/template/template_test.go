@@ -0,0 +1,149 @@
+package template
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+const (
+	uncompressedKey = "0450863ad64a87ae8a2fe83c1af1a8403cb53f53e486d8511dad8a04887e5b23522cd470243453a299fa9e77237716103abc11a1df38855ed6f2ee187e9c582ba6"
+	compressedKey   = "0250863ad64a87ae8a2fe83c1af1a8403cb53f53e486d8511dad8a04887e5b2352"
+)
+
+func TestMatch(t *testing.T) {
+
+	//Expected addresses were generated with btcd txscript.ExtractPkScriptAddrs
+	tests := []struct {
+		script   string
+		class    Class
+		required int
+		mainNet  []string
+		testNet  []string
+	}{
+		{
+			"41" + uncompressedKey + "ac",
+			PubKey, 1,
+			[]string{"16UwLL9Risc3QfPqBUvKofHmBQ7wMtjvM"},
+			[]string{"mfcSEPR8EkJrpX91YkTJ9iscdAzppJrG9j"},
+		},
+		{
+			"21" + compressedKey + "ac",
+			PubKey, 1,
+			[]string{"1PMycacnJaSqwwJqjawXBErnLsZ7RkXUAs"},
+			[]string{"n3svudhm7bt6j3nTT9uu1A57Cs9pKK3iXW"},
+		},
+		//Hybrid keys are accepted by consensus in a bare PubKey output
+		{
+			"41" + "06" + uncompressedKey[2:] + "ac",
+			PubKey, 1,
+			[]string{"1Mfnik6pqw6ze1chS8Z3kzoGMbrCCf81ML"},
+			[]string{"n2Bk1oBoexYFR86K9hXRav1bDbSu4qyG7Q"},
+		},
+		{
+			"76a914010966776006953d5567439e5e39f86a0d273bee88ac",
+			PubKeyHash, 1,
+			[]string{"16UwLL9Risc3QfPqBUvKofHmBQ7wMtjvM"},
+			[]string{"mfcSEPR8EkJrpX91YkTJ9iscdAzppJrG9j"},
+		},
+		{
+			"a914748284390f9e263a4b766a75d0633c50426eb87587",
+			ScriptHash, 1,
+			[]string{"3CK4fEwbMP7heJarmU4eqA3sMbVJyEnU3V"},
+			[]string{"2N3sGiyscxqd3r6DQSbgXT738ZwhUpBqkej"},
+		},
+		{
+			"5121" + compressedKey + "41" + uncompressedKey + "52ae",
+			MultiSig, 1,
+			[]string{"1PMycacnJaSqwwJqjawXBErnLsZ7RkXUAs", "16UwLL9Risc3QfPqBUvKofHmBQ7wMtjvM"},
+			[]string{"n3svudhm7bt6j3nTT9uu1A57Cs9pKK3iXW", "mfcSEPR8EkJrpX91YkTJ9iscdAzppJrG9j"},
+		},
+		{"6a0568656c6c6f", NullData, 0, nil, nil},
+		{"6a", NullData, 0, nil, nil},
+		{"6a5150", NullData, 0, nil, nil},
+		{"6a4c50" + strings.Repeat("aa", 80), NullData, 0, nil, nil},
+		{"6a4c51" + strings.Repeat("aa", 81), NonStandard, 0, nil, nil},
+		{"6a4c50" + strings.Repeat("aa", 80) + "51", NonStandard, 0, nil, nil},
+
+		{"", NonStandard, 0, nil, nil},
+		{"6a76", NonStandard, 0, nil, nil},
+		{"6a4c", NonStandard, 0, nil, nil},
+		{"51", NonStandard, 0, nil, nil},
+		//PUSHDATA1 is not a standard encoding of the hash
+		{"a94c14748284390f9e263a4b766a75d0633c50426eb87587", NonStandard, 0, nil, nil},
+		{"a914748284390f9e263a4b766a75d0633c50426eb87588", NonStandard, 0, nil, nil},
+		{"a914748284390f9e263a4b766a75d0633c50426eb8758787", NonStandard, 0, nil, nil},
+		{"76a914010966776006953d5567439e5e39f86a0d273bee88", NonStandard, 0, nil, nil},
+		//Invalid key prefix
+		{"21" + "05" + compressedKey[2:] + "ac", NonStandard, 0, nil, nil},
+		{"41" + "02" + uncompressedKey[2:] + "ac", NonStandard, 0, nil, nil},
+		{"41" + "05" + uncompressedKey[2:] + "ac", NonStandard, 0, nil, nil},
+		{"21" + "06" + compressedKey[2:] + "ac", NonStandard, 0, nil, nil},
+		//m greater than n, n not matching the key count, zero m
+		{"5221" + compressedKey + "51ae", NonStandard, 0, nil, nil},
+		{"5121" + compressedKey + "52ae", NonStandard, 0, nil, nil},
+		{"0021" + compressedKey + "51ae", NonStandard, 0, nil, nil},
+		{"5151ae", NonStandard, 0, nil, nil},
+	}
+
+	for _, test := range tests {
+		script, _ := hex.DecodeString(test.script)
+
+		match := Match(script)
+		if match.Class != test.class || match.Required != test.required {
+			t.Errorf("%s expected %v %v got %v %v", test.script, test.class, test.required, match.Class, match.Required)
+			continue
+		}
+
+		checkAddresses(t, test.script, match.Addresses(MainNet), test.mainNet)
+		checkAddresses(t, test.script, match.Addresses(TestNet), test.testNet)
+	}
+}
+
+func checkAddresses(t *testing.T, script string, addresses, expected []string) {
+	if len(addresses) != len(expected) {
+		t.Errorf("%s expected addresses %v got %v", script, expected, addresses)
+		return
+	}
+
+	for i := range addresses {
+		if addresses[i] != expected[i] {
+			t.Errorf("%s expected addresses %v got %v", script, expected, addresses)
+			return
+		}
+	}
+}
+
+func TestExtractedValues(t *testing.T) {
+	key, _ := hex.DecodeString(compressedKey)
+	hash, _ := hex.DecodeString("010966776006953d5567439e5e39f86a0d273bee")
+
+	script, _ := hex.DecodeString("21" + compressedKey + "ac")
+	match := Match(script)
+	if len(match.PublicKeys) != 1 || !bytes.Equal(match.PublicKeys[0], key) {
+		t.Errorf("PubKey key mismatch %x", match.PublicKeys)
+	}
+
+	script, _ = hex.DecodeString("76a914010966776006953d5567439e5e39f86a0d273bee88ac")
+	match = Match(script)
+	if !bytes.Equal(match.Hash, hash) {
+		t.Errorf("PubKeyHash hash mismatch %x", match.Hash)
+	}
+
+	script, _ = hex.DecodeString("6a0568656c6c6f00")
+	match = Match(script)
+	if len(match.Data) != 2 || string(match.Data[0]) != "hello" || len(match.Data[1]) != 0 {
+		t.Errorf("NullData data mismatch %x", match.Data)
+	}
+}
+
+func TestClassString(t *testing.T) {
+	if MultiSig.String() != "MultiSig" {
+		t.Errorf("Expected MultiSig got %s", MultiSig)
+	}
+
+	if Class(200).String() != "Class(200)" {
+		t.Errorf("Expected Class(200) got %s", Class(200))
+	}
+}
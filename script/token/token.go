@@ -14,6 +14,8 @@ const (
 	NOTIF
 	ELSE
 	ENDIF
+	BYTECODE
+	PLACEHOLDER //Data holds the placeholder name
 )
 
 var tokens = [...]string{
@@ -27,6 +29,8 @@ var tokens = [...]string{
 	NOTIF:         "NOTIF",
 	ELSE:          "ELSE",
 	ENDIF:         "ENDIF",
+	BYTECODE:      "BYTECODE",
+	PLACEHOLDER:   "PLACEHOLDER",
 }
 
 func (this Token) String() string {
@@ -0,0 +1,96 @@
+package transaction
+
+import (
+	"github.com/spearson78/guardian/crypto/sha256d"
+	"github.com/spearson78/guardian/dataio"
+	"strconv"
+)
+
+const (
+	SigHashAll          uint32 = 0x01
+	SigHashNone         uint32 = 0x02
+	SigHashSingle       uint32 = 0x03
+	SigHashAnyoneCanPay uint32 = 0x80
+
+	sigHashMask uint32 = 0x1f
+)
+
+type InputIndexError int
+
+func (e InputIndexError) Error() string {
+	return "Input Index Out Of Range " + strconv.Itoa(int(e))
+}
+
+// SignatureHash computes the digest that is signed by the signature for input inputIndex.
+//
+// subScript is the script being executed from the last executed CODESEPARATOR with the signatures
+// and any remaining CODESEPARATORs removed, it is hashed as given.
+func (this *Tx) SignatureHash(inputIndex int, subScript []byte, hashType uint32) ([]byte, error) {
+
+	if inputIndex < 0 || inputIndex >= len(this.Inputs) {
+		return nil, InputIndexError(inputIndex)
+	}
+
+	if hashType&sigHashMask == SigHashSingle && inputIndex >= len(this.Outputs) {
+		//Due to a bug in the reference client the value 1 is signed when there is no matching output.
+		one := make([]byte, sha256d.Size)
+		one[0] = 0x01
+		return one, nil
+	}
+
+	txCopy := Tx{
+		Version:  this.Version,
+		LockTime: this.LockTime,
+	}
+
+	if hashType&SigHashAnyoneCanPay != 0 {
+		txCopy.Inputs = []TxIn{
+			TxIn{
+				Previous: this.Inputs[inputIndex].Previous,
+				Script:   subScript,
+				Sequence: this.Inputs[inputIndex].Sequence,
+			},
+		}
+	} else {
+		txCopy.Inputs = make([]TxIn, len(this.Inputs))
+		for i, txIn := range this.Inputs {
+			txCopy.Inputs[i].Previous = txIn.Previous
+			txCopy.Inputs[i].Sequence = txIn.Sequence
+			if i == inputIndex {
+				txCopy.Inputs[i].Script = subScript
+			} else if hashType&sigHashMask == SigHashNone || hashType&sigHashMask == SigHashSingle {
+				//Other inputs may be updated
+				txCopy.Inputs[i].Sequence = 0
+			}
+		}
+	}
+
+	switch hashType & sigHashMask {
+	case SigHashNone:
+		txCopy.Outputs = nil
+	case SigHashSingle:
+		txCopy.Outputs = make([]TxOut, inputIndex+1)
+		for i := 0; i < inputIndex; i++ {
+			txCopy.Outputs[i].Value = -1
+		}
+		txCopy.Outputs[inputIndex] = this.Outputs[inputIndex]
+	default:
+		txCopy.Outputs = this.Outputs
+	}
+
+	h := sha256d.New()
+
+	_, err := txCopy.WriteTo(h)
+	if err != nil {
+		return nil, err
+	}
+
+	var dw dataio.DataWriter
+	dw.Init(h)
+	err = dw.WriteUint32(hashType)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
@@ -93,6 +93,75 @@ func TestGenesisTransaction(t *testing.T) {
 	}
 }
 
+func TestSignatureHash(t *testing.T) {
+
+	//Transaction c99c49da4c38af669dea436d3e73780dfdb6c1ecf9958baa52960e8baee30e73 is signed with the undefined hash type 0
+	c99c49da := "01000000010276b76b07f4935c70acf54fbf1f438a4c397a9fb7e633873c4dd3bc062b6b40000000008c493046022100d23459d03ed7e9511a47d13292d3430a04627de6235b6e51a40f9cd386f2abe3022100e7d25b080f0bb8d8d5f878bba7d54ad2fda650ea8d158a33ee3cbd11768191fd004104b0e2c879e4daf7b9ab68350228c159766676a14f5815084ba166432aab46198d4cca98fa3e9981d0a90b2effc514b76279476550ba3663fdcaff94c38420e9d5000000000100093d00000000001976a9149a7b0f3b80c6baaeedce0a0842553800f832ba1f88ac00000000"
+
+	//Transaction afd9c17f8913577ec3509520bd6e5d63e9c0fd2a5f70c787993b097ba6ca9fae has several SIGHASH_SINGLE signatures
+	afd9c17f := "010000000370ac0a1ae588aaf284c308d67ca92c69a39e2db81337e563bf40c59da0a5cf63000000006a4730440220360d20baff382059040ba9be98947fd678fb08aab2bb0c172efa996fd8ece9b702201b4fb0de67f015c90e7ac8a193aeab486a1f587e0f54d0fb9552ef7f5ce6caec032103579ca2e6d107522f012cd00b52b9a65fb46f0c57b9b8b6e377c48f526a44741affffffff7d815b6447e35fbea097e00e028fb7dfbad4f3f0987b4734676c84f3fcd0e804010000006b483045022100c714310be1e3a9ff1c5f7cacc65c2d8e781fc3a88ceb063c6153bf950650802102200b2d0979c76e12bb480da635f192cc8dc6f905380dd4ac1ff35a4f68f462fffd032103579ca2e6d107522f012cd00b52b9a65fb46f0c57b9b8b6e377c48f526a44741affffffff3f1f097333e4d46d51f5e77b53264db8f7f5d2e18217e1099957d0f5af7713ee010000006c493046022100b663499ef73273a3788dea342717c2640ac43c5a1cf862c9e09b206fcb3f6bb8022100b09972e75972d9148f2bdd462e5cb69b57c1214b88fc55ca638676c07cfc10d8032103579ca2e6d107522f012cd00b52b9a65fb46f0c57b9b8b6e377c48f526a44741affffffff0380841e00000000001976a914bfb282c70c4191f45b5a6665cad1682f2c9cfdfb88ac80841e00000000001976a9149857cc07bed33a5cf12b9c5e0500b675d500c81188ace0fd1c00000000001976a91443c52850606c872403c0601e69fa34b26f62db4a88ac00000000"
+	afd9c17fScript := "76a914dcf72c4fd02f5a987cf9b02f2fabfcac3341a87d88ac"
+
+	//From the reference client's tx_valid.json, not a mainnet transaction, the first input is signed with SIGHASH_ALL
+	//and the second with SIGHASH_ALL|SIGHASH_ANYONECANPAY
+	anyoneCanPay := "010000000200010000000000000000000000000000000000000000000000000000000000000000000049483045022100d180fd2eb9140aeb4210c9204d3f358766eb53842b2a9473db687fa24b12a3cc022079781799cd4f038b85135bbe49ec2b57f306b2bb17101b17f71f000fcab2b6fb01ffffffff0002000000000000000000000000000000000000000000000000000000000000000000004847304402205f7530653eea9b38699e476320ab135b74771e1c48b81a5d041e2ca84b9be7a802200ac8d1f40fb026674fe5a5edd3dea715c27baa9baca51ed45ea750ac9dc0a55e81ffffffff010100000000000000015100000000"
+	anyoneCanPayScript := "21035e7f0d4d0841bcd56c39337ed086b1a633ee770c1ffdd94ac552a95ac2ce0efcac"
+
+	//Transaction 5df1375ffe61ac35ca178ebb0cab9ea26dedbd0e96005dfcee7e379fa513232f signs its second input with SIGHASH_SINGLE but has only one output
+	singleBug := "0100000002f9cbafc519425637ba4227f8d0a0b7160b4e65168193d5af39747891de98b5b5000000006b4830450221008dd619c563e527c47d9bd53534a770b102e40faa87f61433580e04e271ef2f960220029886434e18122b53d5decd25f1f4acb2480659fea20aabd856987ba3c3907e0121022b78b756e2258af13779c1a1f37ea6800259716ca4b7f0b87610e0bf3ab52a01ffffffff42e7988254800876b69f24676b3e0205b77be476512ca4d970707dd5c60598ab00000000fd260100483045022015bd0139bcccf990a6af6ec5c1c52ed8222e03a0d51c334df139968525d2fcd20221009f9efe325476eb64c3958e4713e9eefe49bf1d820ed58d2112721b134e2a1a53034930460221008431bdfa72bc67f9d41fe72e94c88fb8f359ffa30b33c72c121c5a877d922e1002210089ef5fc22dd8bfc6bf9ffdb01a9862d27687d424d1fefbab9e9c7176844a187a014c9052483045022015bd0139bcccf990a6af6ec5c1c52ed8222e03a0d51c334df139968525d2fcd20221009f9efe325476eb64c3958e4713e9eefe49bf1d820ed58d2112721b134e2a1a5303210378d430274f8c5ec1321338151e9f27f4c676a008bdf8638d07c0b6be9ab35c71210378d430274f8c5ec1321338151e9f27f4c676a008bdf8638d07c0b6be9ab35c7153aeffffffff01a08601000000000017a914d8dacdadb7462ae15cd906f1878706d0da8660e68700000000"
+	singleBugScript := "52210378d430274f8c5ec1321338151e9f27f4c676a008bdf8638d07c0b6be9ab35c71210378d430274f8c5ec1321338151e9f27f4c676a008bdf8638d07c0b6be9ab35c7153ae"
+
+	tests := []struct {
+		tx         string
+		inputIndex int
+		subScript  string
+		hashType   uint32
+		hash       string
+	}{
+		{c99c49da, 0, "76a914dc44b1164188067c3a32d4780f5996fa14a4f2d988ac", 0x00, "11743b220e9e24e89abd4ff124a2740531fe7d7f9b4e26de14710a532fd543e2"},
+		{afd9c17f, 0, afd9c17fScript, SigHashSingle, "465f0318f9801d56aeb1737d1e9af8e27d841a493b11494a9283477a1fdccc1e"},
+		{afd9c17f, 1, afd9c17fScript, SigHashSingle, "3b8eee7ff2be39a1d7a69cfdfda487985caa090f33858a60de0b4df67ae84319"},
+		{afd9c17f, 2, afd9c17fScript, SigHashSingle, "d694785005d3292d4771695034ee3f07b4dc8a3bacfdb0ecf221a7a68d061f1c"},
+		{afd9c17f, 1, afd9c17fScript, SigHashNone, "7f7c9c47900c389219a7264766ad5b92b1cd6ba1283f645c36230a6e98036ccb"},
+		{afd9c17f, 1, afd9c17fScript, SigHashAll | SigHashAnyoneCanPay, "10a3af2a1ec1a5c7dcbb47593f7db5a31d1bc53836a0024ef2776e94f802738d"},
+		{afd9c17f, 1, afd9c17fScript, SigHashNone | SigHashAnyoneCanPay, "1137bf254a97fb388eacc954710285fa1fc001c108f30b963d3ef4d7cc3fb850"},
+		{afd9c17f, 1, afd9c17fScript, SigHashSingle | SigHashAnyoneCanPay, "ce88bf96ef0f5c4f04e9eb24e5c5972cfa6400e9583ef4be2bcd4bbbb634e665"},
+		{anyoneCanPay, 0, anyoneCanPayScript, SigHashAll, "f69b639c5d2ee6f886701efaf4616daa84793a48d851d19434bb6a13dd6225cc"},
+		{anyoneCanPay, 1, anyoneCanPayScript, SigHashAll | SigHashAnyoneCanPay, "57f5a54d548db73fa8ef7a43d011120f9935fe792f0a0630d28ee70b4c72a7e8"},
+		{singleBug, 1, singleBugScript, SigHashSingle, "0100000000000000000000000000000000000000000000000000000000000000"},
+	}
+
+	for _, test := range tests {
+		var tx Tx
+		raw, _ := hex.DecodeString(test.tx)
+		err := tx.Set(raw)
+		if err != nil {
+			t.Errorf(".Set() failed %v", err)
+			continue
+		}
+
+		subScript, _ := hex.DecodeString(test.subScript)
+		hash, err := tx.SignatureHash(test.inputIndex, subScript, test.hashType)
+		if err != nil {
+			t.Errorf(".SignatureHash() failed %v", err)
+			continue
+		}
+
+		if hex.EncodeToString(hash) != test.hash {
+			t.Errorf(".SignatureHash() input %d hashType %x incorrect %s", test.inputIndex, test.hashType, hex.EncodeToString(hash))
+		}
+	}
+
+	var tx Tx
+	raw, _ := hex.DecodeString(afd9c17f)
+	tx.Set(raw)
+
+	_, err := tx.SignatureHash(3, nil, SigHashAll)
+	if err != InputIndexError(3) {
+		t.Errorf(".SignatureHash() expected InputIndexError got %v", err)
+	}
+}
+
 func BenchmarkBytes(b *testing.B) {
 
 	tx := Tx{